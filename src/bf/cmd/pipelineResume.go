@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"bioflows/cli"
+	"errors"
+	"github.com/spf13/cobra"
+)
+
+var workflowResumeCmd = &cobra.Command{
+	Use:"resume [workflow instance ID]",
+	Short: "Resumes a previous run of a workflow from its output directory, skipping the steps which have already succeeded.",
+	Long:"Resumes a previous run of a workflow from its output directory, skipping the steps which have already succeeded. " +
+		"The workflow instance ID is printed when the workflow starts running.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1{
+			return errors.New("Please provide the instance ID of the workflow to resume.")
+		}
+		if len(OutputDir) < 1 {
+			return errors.New("Output Directory Flag is required.")
+		}
+		ctx , cancel := newInterruptContext()
+		defer cancel()
+		return cli.ResumePipeline(ctx,OutputDir,args[0],clean,maxParallel)
+	},
+}
+
+func init(){
+	workflowResumeCmd.Flags().BoolVar(&clean,"clean",false,"This command cleans all metadata associated with this pipeline from the distributed in-memory Key/Value Store, " +
+		"in case you are running in a distributed mode. this command has no effect if you are running in a local mode.")
+	workflowResumeCmd.Flags().IntVar(&maxParallel,"max-parallel",0,"The maximum number of steps that are allowed to run at the same time on the current node, " +
+		"zero means no limit other than the available CPU cores and memory.")
+	WorkflowCmd.AddCommand(workflowResumeCmd)
+}
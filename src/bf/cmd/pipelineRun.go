@@ -10,6 +10,7 @@ import (
 
 var (
 	clean          bool
+	maxParallel    int
 	positionalArgs models.FlowConfig
 )
 
@@ -28,7 +29,9 @@ var workflowRunCmd = &cobra.Command{
 			return errors.New("Output Directory Flag is required.")
 		}
 		toolPath := args[0]
-		return cli.RunPipeline(cfgFile,toolPath,OutputDir,DataDir, initialsConfig,clean, positionalArgs)
+		ctx , cancel := newInterruptContext()
+		defer cancel()
+		return cli.RunPipeline(ctx,cfgFile,toolPath,OutputDir,DataDir, initialsConfig,clean,maxParallel, positionalArgs)
 	},
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) < 1{
@@ -55,6 +58,8 @@ func parseArgs(args []string) (models.FlowConfig,error){
 func init(){
 	workflowRunCmd.PersistentFlags().BoolVar(&clean,"clean",false,"This command cleans all metadata associated with this pipeline from the distributed in-memory Key/Value Store, " +
 		"in case you are running in a distributed mode. this command has no effect if you are running in a local mode.")
+	workflowRunCmd.PersistentFlags().IntVar(&maxParallel,"max-parallel",0,"The maximum number of steps that are allowed to run at the same time on the current node, " +
+		"zero means no limit other than the available CPU cores and memory.")
 
 	workflowRunCmd.MarkFlagRequired(OutputDir)
 	workflowRunCmd.MarkFlagRequired(DataDir)
@@ -38,7 +38,9 @@ var runCmd = &cobra.Command{
 			return errors.New("Output Directory Flag is required.")
 		}
 		toolPath := args[0]
-		return cli.RunTool(cfgFile,toolPath,WorkflowId,WorkflowName,OutputDir,DataDir, initialsConfig,positionalArgs)
+		ctx , cancel := newInterruptContext()
+		defer cancel()
+		return cli.RunTool(ctx,cfgFile,toolPath,WorkflowId,WorkflowName,OutputDir,DataDir, initialsConfig,positionalArgs)
 	},
 	Args: func(cmd *cobra.Command, args []string) error {
 		positionalArgs, _ = parseArgs(args[1:])
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// newInterruptContext returns a context which is cancelled once SIGINT or SIGTERM is received,
+// a second signal falls back to the default behaviour and terminates the process immediately.
+func newInterruptContext() (context.Context,context.CancelFunc) {
+	ctx , cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal,1)
+	signal.Notify(signals,os.Interrupt,syscall.SIGTERM)
+	go func(){
+		select {
+		case sig := <- signals:
+			fmt.Println(fmt.Sprintf("Received (%v), cancelling all running steps....",sig))
+			cancel()
+		case <- ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx , cancel
+}
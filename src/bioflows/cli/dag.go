@@ -4,7 +4,6 @@ import (
 	"bioflows/helpers"
 	"bioflows/models/pipelines"
 	"fmt"
-	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"os"
 )
@@ -24,7 +23,7 @@ func RenderGraphViz(pipelineFile string) (string,error) {
 			fmt.Printf("Error reading the contents of the tool , %v\n",err)
 			os.Exit(1)
 		}
-		err = yaml.Unmarshal([]byte(mytool_content),pipeline)
+		err = pipelines.ParsePipeline(mytool_content,pipeline)
 		if err != nil {
 			//fmt.Println("There was a problem unmarshaling the current tool")
 			fmt.Println(err.Error())
@@ -4,15 +4,52 @@ import (
 	"bioflows/config"
 	"bioflows/executors"
 	"bioflows/helpers"
+	"bioflows/managers"
 	"bioflows/models"
 	"bioflows/models/pipelines"
+	"bioflows/resolver"
+	"context"
 	"fmt"
-	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"os"
 )
 
-func RunPipeline(configFile,toolPath,outputDir,dataDir, initialsConfig string,clean bool,pconfig models.FlowConfig) error{
+const (
+	RUN_CONFIG_FILE = "config_file"
+	RUN_TOOL_PATH = "tool_path"
+	RUN_DATA_DIR = "data_dir"
+	RUN_INITIALS = "initials"
+	RUN_ARGS = "args"
+)
+
+func RunPipeline(ctx context.Context,configFile,toolPath,outputDir,dataDir, initialsConfig string,clean bool,maxParallel int,pconfig models.FlowConfig) error{
+	return runPipeline(ctx,"",configFile,toolPath,outputDir,dataDir,initialsConfig,clean,maxParallel,pconfig)
+}
+
+// ResumePipeline runs again a previous workflow instance from the given output directory, skipping its succeeded steps
+func ResumePipeline(ctx context.Context,outputDir,instanceId string,clean bool,maxParallel int) error {
+	stateManager , err := managers.GetFileStateManager(outputDir)
+	if err != nil {
+		return err
+	}
+	data , err := stateManager.GetStateByID(resolver.ResolveRunKey(instanceId))
+	if err != nil {
+		return err
+	}
+	run , ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("Unable to find a previous run with instance ID (%s) in %s",instanceId,outputDir)
+	}
+	pconfig := models.FlowConfig{}
+	if args , ok := run[RUN_ARGS].(map[string]interface{}); ok {
+		pconfig.Fill(args)
+	}
+	fmt.Println(fmt.Sprintf("Resuming Workflow Instance: %s",instanceId))
+	return runPipeline(ctx,instanceId,fmt.Sprintf("%v",run[RUN_CONFIG_FILE]),fmt.Sprintf("%v",run[RUN_TOOL_PATH]),outputDir,
+		fmt.Sprintf("%v",run[RUN_DATA_DIR]),fmt.Sprintf("%v",run[RUN_INITIALS]),clean,maxParallel,pconfig)
+}
+
+func runPipeline(ctx context.Context,instanceId,configFile,toolPath,outputDir,dataDir, initialsConfig string,clean bool,maxParallel int,pconfig models.FlowConfig) error{
 	fmt.Println(fmt.Sprintf("Using Configuration File: %s",configFile))
 	pipeline := &pipelines.BioPipeline{}
 	workflowConfig := models.FlowConfig{}
@@ -35,7 +72,7 @@ func RunPipeline(configFile,toolPath,outputDir,dataDir, initialsConfig string,cl
 			fmt.Println(fmt.Sprintf("Error: %s",err.Error()))
 			return err
 		}
-		err = yaml.Unmarshal([]byte(mypipeline_contents),pipeline)
+		err = pipelines.ParsePipeline(mypipeline_contents,pipeline)
 		if err != nil {
 			fmt.Printf("Error: %s",err.Error())
 			return err
@@ -62,6 +99,9 @@ func RunPipeline(configFile,toolPath,outputDir,dataDir, initialsConfig string,cl
 	workflowConfig[config.WF_INSTANCE_OUTDIR] = outputDir
 	workflowConfig[config.WF_INSTANCE_DATADIR] = dataDir
 	workflowConfig.Fill(pconfig)
+	if maxParallel > 0 {
+		workflowConfig[config.WF_MAX_PARALLEL] = maxParallel
+	}
 	if len(initialsConfig) > 0 {
 		initialParams, err := ReadParamsConfig(initialsConfig)
 		if err != nil {
@@ -69,6 +109,12 @@ func RunPipeline(configFile,toolPath,outputDir,dataDir, initialsConfig string,cl
 		}
 		workflowConfig.Fill(initialParams)
 	}
+	// Missing or invalid inputs of the workflow are reported before anything executes
+	err = executors.ResolveParameters(pipeline.Inputs,workflowConfig,true)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
 	fmt.Println(fmt.Sprintf("Executing Workflow: %s",pipeline.Name))
 	executor := executors.DagExecutor{}
 	err = executor.Setup(workflowConfig)
@@ -76,7 +122,31 @@ func RunPipeline(configFile,toolPath,outputDir,dataDir, initialsConfig string,cl
 		fmt.Println(fmt.Sprintf("Error: %s",err.Error()))
 		return err
 	}
-	err =  executor.Run(pipeline,workflowConfig)
+	if len(instanceId) > 0 {
+		executor.SetInstanceId(instanceId)
+	}
+	// Keep what is needed to resume the current run later
+	err = executor.GetContext().SaveState(resolver.ResolveRunKey(executor.GetInstanceId()),map[string]interface{}{
+		RUN_CONFIG_FILE: configFile,
+		RUN_TOOL_PATH: toolPath,
+		RUN_DATA_DIR: dataDir,
+		RUN_INITIALS: initialsConfig,
+		RUN_ARGS: pconfig.GetAsMap(),
+	})
+	if err == nil {
+		// The run should be resumable even if BioFlows is killed right away
+		err = executor.GetContext().Flush()
+	}
+	if err != nil {
+		fmt.Println(fmt.Sprintf("Error: %s",err.Error()))
+	}
+	fmt.Println(fmt.Sprintf("Workflow Instance ID: %s",executor.GetInstanceId()))
+	err =  executor.RunContext(ctx,pipeline,workflowConfig)
+	if flushErr := executor.GetContext().Flush(); flushErr != nil {
+		fmt.Println(fmt.Sprintf("Error: %s",flushErr.Error()))
+	}
+	fmt.Println("Run Summary:")
+	fmt.Println(GetRunSummaryTable(&executor,pipeline).String())
 	if clean {
 		executor.Clean()
 	}
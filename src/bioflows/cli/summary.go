@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"bioflows/executors"
+	"bioflows/models"
+	"bioflows/models/pipelines"
+	"fmt"
+	"github.com/alexeyco/simpletable"
+	"time"
+)
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+func formatMemory(bytes int64) string {
+	return fmt.Sprintf("%.1f MB",float64(bytes) / (1024 * 1024))
+}
+
+// GetRunSummaryTable lists the state and the resources used by every step of the given pipeline once it has run
+func GetRunSummaryTable(executor *executors.DagExecutor , pipeline *pipelines.BioPipeline) *simpletable.Table {
+	table := simpletable.New()
+	table.Header = &simpletable.Header{
+		Cells: []*simpletable.Cell{
+			{Align: simpletable.AlignCenter,Text: "Step"},
+			{Align: simpletable.AlignCenter,Text: "State"},
+			{Align: simpletable.AlignCenter,Text: "Wall Time"},
+			{Align: simpletable.AlignCenter,Text: "User CPU"},
+			{Align: simpletable.AlignCenter,Text: "System CPU"},
+			{Align: simpletable.AlignCenter,Text: "Peak RSS"},
+		},
+	}
+	for _ , step := range pipeline.Steps {
+		stepState := executor.GetStepState(step)
+		state := "not run"
+		if data , ok := stepState.(map[string]interface{}); ok {
+			if value , ok := data[models.STEP_STATE_KEY]; ok {
+				state = fmt.Sprintf("%v",value)
+			}
+			if reason , ok := data[models.STEP_FAILURE_REASON_KEY]; ok {
+				state = fmt.Sprintf("%s (%v)",state,reason)
+			}
+		}
+		r := []*simpletable.Cell{
+			{Align: simpletable.AlignLeft,Text: step.ID},
+			{Align: simpletable.AlignCenter,Text: state},
+		}
+		if usage , ok := models.GetUsageFromState(stepState); ok {
+			r = append(r,
+				&simpletable.Cell{Align: simpletable.AlignRight,Text: formatDuration(usage.WallTime)},
+				&simpletable.Cell{Align: simpletable.AlignRight,Text: formatDuration(usage.UserTime)},
+				&simpletable.Cell{Align: simpletable.AlignRight,Text: formatDuration(usage.SystemTime)},
+				&simpletable.Cell{Align: simpletable.AlignRight,Text: formatMemory(usage.PeakRSS)})
+		}else{
+			for idx := 0 ; idx < 4 ; idx++ {
+				r = append(r,&simpletable.Cell{Align: simpletable.AlignRight,Text: "-"})
+			}
+		}
+		table.Body.Cells = append(table.Body.Cells,r)
+	}
+	total := executor.GetUsage()
+	table.Footer = &simpletable.Footer{
+		Cells: []*simpletable.Cell{
+			{Align: simpletable.AlignLeft,Text: "Total"},
+			{},
+			{Align: simpletable.AlignRight,Text: formatDuration(total.WallTime)},
+			{Align: simpletable.AlignRight,Text: formatDuration(total.UserTime)},
+			{Align: simpletable.AlignRight,Text: formatDuration(total.SystemTime)},
+			{Align: simpletable.AlignRight,Text: formatMemory(total.PeakRSS)},
+		},
+	}
+	return table
+}
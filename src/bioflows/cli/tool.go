@@ -6,6 +6,7 @@ import (
 	"bioflows/helpers"
 	"bioflows/models"
 	"bioflows/models/pipelines"
+	"context"
 	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
@@ -47,7 +48,7 @@ func GetAttachableVolumes(step *pipelines.BioPipeline) ([]models.Parameter, erro
 	return volumes , nil
 }
 
-func RunTool(configFile string, toolPath string,workflowId string ,
+func RunTool(ctx context.Context,configFile string, toolPath string,workflowId string ,
 	workflowName string,outputDir string,dataDir string,
 	initialsConfig string,
 	tconfig models.FlowConfig) error{
@@ -103,21 +104,31 @@ func RunTool(configFile string, toolPath string,workflowId string ,
 		}
 		workflowConfig.Fill(initialParams)
 	}
+	// Missing or invalid inputs of the tool are reported before anything executes
+	err = executors.ResolveParameters(tool.Inputs,workflowConfig,true)
+	if err != nil {
+		fmt.Println(err.Error())
+		return err
+	}
 	tool_name := tool.Name
 	if len(tool_name) <= 0 {
 		tool_name = workflowName
 	}
-	var funcCall func(*models.ToolInstance , models.FlowConfig) (models.FlowConfig,error)
-	if tool.Loop {
-		funcCall = executor.RunToolLoop
+	var funcCall func(context.Context,*models.ToolInstance , models.FlowConfig) (models.FlowConfig,error)
+	if tool.IsLoop() {
+		funcCall = executor.RunToolLoopContext
 	}else{
 		// The tool is not loop
-		funcCall = executor.Run
+		funcCall = executor.RunContext
 	}
-	_ , err = funcCall(&models.ToolInstance{WorkflowID: workflowId,Name: workflowName ,WorkflowName: workflowName,Tool:tool.ToTool()},workflowConfig)
+	toolConfig , err := funcCall(ctx,&models.ToolInstance{WorkflowID: workflowId,Name: workflowName ,WorkflowName: workflowName,Tool:tool.ToTool()},workflowConfig)
 	if err != nil {
 		fmt.Println(err)
 	}
+	if usage , ok := models.GetUsageFromState(toolConfig); ok {
+		fmt.Println(fmt.Sprintf("Wall Time: %s, User CPU: %s, System CPU: %s, Peak RSS: %s",formatDuration(usage.WallTime),
+			formatDuration(usage.UserTime),formatDuration(usage.SystemTime),formatMemory(usage.PeakRSS)))
+	}
 	return err
 
 }
@@ -5,7 +5,6 @@ import (
 	"bioflows/models/pipelines"
 	"errors"
 	"fmt"
-	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -45,10 +44,14 @@ func ValidateYAML(filePath string) (bool,error){
 			return false, err
 		}
 	}
-	err = yaml.Unmarshal(data,pipeline)
+	err = pipelines.ParsePipeline(data,pipeline)
 	if err != nil {
 		err = errors.New(fmt.Sprintf("Error Validating the file: %s",err.Error()))
 		return false , err
 	}
+	_ , err = pipelines.CreateGraph(pipeline)
+	if err != nil {
+		return false , err
+	}
 	return true , nil
 }
\ No newline at end of file
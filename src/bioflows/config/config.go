@@ -16,6 +16,8 @@ const (
 	BIOFLOWS_PIPELINES = "pipelines"
 	BIOFLOWS_NODES     = "nodes"
 	BIOFLOWS_LEADER    = "leader"
+	BIOFLOWS_RUNS      = "runs"
+	BIOFLOWS_CACHE     = "cache"
 )
 
 
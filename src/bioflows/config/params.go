@@ -11,4 +11,12 @@ const (
 	WF_BF_TOOL_PATH = "bf_tool_path"
 	WF_BF_TOOL_LOCAL = "bf_tool_local_local"
 	WF_BF_TOOL_BASEPATH = "bf_tool_basepath"
+	WF_BF_STEPS = "steps"
+	WF_RESOURCES = "resources"
+	WF_MAX_PARALLEL = "max_parallel"
+	WF_STEP_DIR_LAYOUT = "step_dir_layout"
+	WF_SCRATCH_ROOT = "scratch_root"
+	WF_PUBLISH_DIR = "publish_dir"
+	WF_PULL_POLICY = "pull_policy"
+	BIOFLOWS_STATE_FILE = "bioflows.state.json"
 )
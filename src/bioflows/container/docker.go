@@ -4,20 +4,31 @@ import (
 	"bioflows/models"
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"io"
 	"log"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
 	DOCKER_REPOSITORY = "docker.io"
 )
 
+// ErrOOMKilled is returned when a container has been killed for running out of memory
+var ErrOOMKilled = errors.New("the container has been killed for running out of memory")
+
 type DockerManager struct {
+	// mutex guards the lazy creation of the client, the manager can be shared by concurrent pulls
+	mutex sync.Mutex
 	client *client.Client
 	logger *log.Logger
 	DockerConfig *container.Config
@@ -26,10 +37,55 @@ type DockerManager struct {
 }
 
 func (d *DockerManager) AddAttachableVolume(volumePath string) {
+	d.AddVolume(volumePath,volumePath,false)
+}
+
+// AddVolume mounts the source path of the host at the target path of the containers, a target which is already mounted is kept as is
+func (d *DockerManager) AddVolume(source string , target string , readOnly bool) {
 	if d.HostConfig == nil {
 		d.HostConfig = &container.HostConfig{}
 	}
-	d.HostConfig.Binds = append(d.HostConfig.Binds,fmt.Sprintf("%s:%s",volumePath))
+	for _ , bind := range d.HostConfig.Binds {
+		parts := strings.Split(bind,":")
+		if len(parts) > 1 && parts[1] == target {
+			return
+		}
+	}
+	bind := fmt.Sprintf("%s:%s",source,target)
+	if readOnly {
+		bind = fmt.Sprintf("%s:ro",bind)
+	}
+	d.HostConfig.Binds = append(d.HostConfig.Binds,bind)
+}
+
+/*
+	Configure applies the caps (cpu cores and memory in MB) as the resource limits of the containers to run,
+	together with the user, the working directory, the entrypoint and the extra volumes of the container configuration.
+	Containers run as the host user invoking BioFlows unless the configuration has another user.
+ */
+func (d *DockerManager) Configure(containerConfig *models.ContainerConfig , caps *models.Capabilities) {
+	if d.HostConfig == nil {
+		d.HostConfig = &container.HostConfig{}
+	}
+	if d.DockerConfig == nil {
+		d.DockerConfig = &container.Config{}
+	}
+	d.HostConfig.NanoCPUs , d.HostConfig.Memory = 0 , 0
+	if caps != nil {
+		d.HostConfig.NanoCPUs = int64(caps.CPU) * 1e9
+		d.HostConfig.Memory = int64(caps.Memory) * 1024 * 1024
+	}
+	d.DockerConfig.User = containerConfig.GetUser()
+	if containerConfig == nil {
+		return
+	}
+	d.DockerConfig.WorkingDir = containerConfig.Workdir
+	if len(containerConfig.Entrypoint) > 0 {
+		d.DockerConfig.Entrypoint = containerConfig.Entrypoint
+	}
+	for _ , volume := range containerConfig.Volumes {
+		d.AddVolume(volume.Source,volume.GetTarget(),volume.ReadOnly)
+	}
 }
 
 func (d *DockerManager) SetLogger(logger *log.Logger) {
@@ -42,6 +98,8 @@ func (d *DockerManager) Log(logs ...interface{}) {
 
 
 func (d *DockerManager) init() error{
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
 	if d.client != nil {
 		return nil
 	}
@@ -54,7 +112,10 @@ func (d *DockerManager) init() error{
 }
 
 func (d *DockerManager) PullImage(imageURL string,containerConfig *models.ContainerConfig) (string,error) {
-	d.init()
+	err := d.init()
+	if err != nil {
+		return "" , err
+	}
 	buffer := &bytes.Buffer{}
 	options := types.ImagePullOptions{}
 	if containerConfig != nil {
@@ -64,67 +125,164 @@ func (d *DockerManager) PullImage(imageURL string,containerConfig *models.Contai
 	if err != nil {
 		return "" , err
 	}
+	defer reader.Close()
 	io.Copy(buffer,reader)
 	return buffer.String() , nil
 }
 
+// GetImageId returns the local ID of the given image, it changes whenever a new version of the image is pulled
+func (d *DockerManager) GetImageId(image string) (string,error) {
+	err := d.init()
+	if err != nil {
+		return "" , err
+	}
+	inspect , _ , err := d.client.ImageInspectWithRaw(context.Background(),image)
+	if err != nil {
+		return "" , err
+	}
+	return inspect.ID , nil
+}
+
+// ContainerUsage is what a container has consumed according to its stats, PeakMemory is in bytes
+type ContainerUsage struct {
+	UserTime time.Duration
+	SystemTime time.Duration
+	PeakMemory int64
+}
+
+// collectStats reads the stats of the container until it stops or the context is done, keeping the latest cpu times and the peak memory
+func (d *DockerManager) collectStats(ctx context.Context,containerId string,usage *ContainerUsage) {
+	stats , err := d.client.ContainerStats(ctx,containerId,true)
+	if err != nil {
+		d.Log(fmt.Sprintf("Unable to read the stats of Container (%s): %s",containerId,err.Error()))
+		return
+	}
+	defer stats.Body.Close()
+	decoder := json.NewDecoder(stats.Body)
+	for {
+		var sample types.StatsJSON
+		if err := decoder.Decode(&sample); err != nil {
+			return
+		}
+		cpu := sample.CPUStats.CPUUsage
+		// A stopped container reports zero cpu usage, keep what was read before
+		if cpu.UsageInUsermode > 0 || cpu.UsageInKernelmode > 0 {
+			usage.UserTime = time.Duration(cpu.UsageInUsermode)
+			usage.SystemTime = time.Duration(cpu.UsageInKernelmode)
+		}
+		memory := sample.MemoryStats.MaxUsage
+		if sample.MemoryStats.Usage > memory {
+			memory = sample.MemoryStats.Usage
+		}
+		if int64(memory) > usage.PeakMemory {
+			usage.PeakMemory = int64(memory)
+		}
+	}
+}
+
 func (d *DockerManager) RunContainer(containerName string , ImageId string, commands []string,keep bool) (Out *bytes.Buffer, Err *bytes.Buffer,err error) {
-	d.init()
 	Out = &bytes.Buffer{}
 	Err = &bytes.Buffer{}
-	err = nil
+	exitCode , _ , err := d.RunContainerContext(context.Background(),containerName,ImageId,commands,nil,Out,Err,keep)
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("Container has exited with code %d",exitCode)
+	}
+	return Out , Err , err
+}
+
+/*
+	RunContainerContext runs the container with the given environment variables (NAME=value) and waits for it to finish,
+	its stdout and stderr are streamed apart into the given writers while it runs. If the context is done before that
+	the container is stopped and removed regardless of the keep flag.
+	It returns the exit code of the container and the resources it has used, ErrOOMKilled is returned when it has run out of memory.
+ */
+func (d *DockerManager) RunContainerContext(ctx context.Context,containerName string , ImageId string, commands []string,env []string,
+	stdout io.Writer , stderr io.Writer ,keep bool) (exitCode int , usage ContainerUsage , err error) {
+	d.init()
 	//randomContainerName := fmt.Sprintf("%s%d",containerName,rand.Int())
-	resp , err := d.client.ContainerCreate(context.Background(),&container.Config{
-		Image: ImageId,
-		Cmd:  commands,
-		Tty:  true,
-	},
+	containerConfig := container.Config{}
+	if d.DockerConfig != nil {
+		containerConfig = *d.DockerConfig
+	}
+	containerConfig.Image = ImageId
+	containerConfig.Cmd = commands
+	containerConfig.Env = env
+	// Without a TTY the logs of the container multiplex its stdout and stderr
+	containerConfig.Tty = false
+	resp , err := d.client.ContainerCreate(ctx,&containerConfig,
 	d.HostConfig,
 	d.NetworkingConfig,
 	nil,"")
 	if err != nil {
 		d.Log(fmt.Sprintf("Error Creating Container : %s",err.Error()))
-		return nil , nil , err
-	}
-	if !keep{
-		defer func(){
-			d.Log(fmt.Sprintf("Stopping Container : %s",resp.ID))
-			stopErr := d.StopContainer(resp.ID)
-			if stopErr != nil {
-				d.Log(fmt.Sprintf("ContainerError: %s",stopErr.Error()))
-			}
-			d.Log(fmt.Sprintf("Deleting Container: %s",resp.ID))
-			delErr := d.DeleteContainer(resp.ID)
-			if delErr != nil{
-				d.Log(fmt.Sprintf("ContainerError: %s",delErr.Error()))
-			}
-		}()
-
-	}else{
-		defer d.Log(fmt.Sprintf("Keeping Container: %s",resp.ID))
+		return 1 , usage , err
 	}
-	err = d.client.ContainerStart(context.Background(),resp.ID,types.ContainerStartOptions{})
+	defer func(){
+		// A cancelled container should never be kept around
+		if keep && ctx.Err() == nil {
+			d.Log(fmt.Sprintf("Keeping Container: %s",resp.ID))
+			return
+		}
+		d.Log(fmt.Sprintf("Stopping Container : %s",resp.ID))
+		stopErr := d.StopContainer(resp.ID)
+		if stopErr != nil {
+			d.Log(fmt.Sprintf("ContainerError: %s",stopErr.Error()))
+		}
+		d.Log(fmt.Sprintf("Deleting Container: %s",resp.ID))
+		delErr := d.DeleteContainer(resp.ID)
+		if delErr != nil{
+			d.Log(fmt.Sprintf("ContainerError: %s",delErr.Error()))
+		}
+	}()
+	err = d.client.ContainerStart(ctx,resp.ID,types.ContainerStartOptions{})
 	if err != nil {
 		fmt.Println(fmt.Sprintf("Container: %s",err.Error()))
-		return nil , nil , err
+		return 1 , usage , err
 	}
-	statusCh , errCh := d.client.ContainerWait(context.Background(),resp.ID,container.WaitConditionNotRunning)
+	// Collect the stats of the container while it runs
+	statsCtx , stopStats := context.WithCancel(ctx)
+	var statsGroup sync.WaitGroup
+	statsGroup.Add(1)
+	go func(){
+		defer statsGroup.Done()
+		d.collectStats(statsCtx,resp.ID,&usage)
+	}()
+	defer func(){
+		stopStats()
+		statsGroup.Wait()
+	}()
+	// Follow the logs of the container until it exits
+	logs , err := d.client.ContainerLogs(ctx,resp.ID,types.ContainerLogsOptions{ShowStderr: true,ShowStdout: true,Follow: true})
+	if err != nil {
+		return 1 , usage , err
+	}
+	defer logs.Close()
+	copied := make(chan struct{})
+	go func(){
+		defer close(copied)
+		stdcopy.StdCopy(stdout,stderr,logs)
+	}()
+	statusCh , errCh := d.client.ContainerWait(ctx,resp.ID,container.WaitConditionNotRunning)
 	select {
 		case err := <- errCh:
-			if err != nil {
-				panic(err)
+			if ctx.Err() != nil {
+				return 1 , usage , ctx.Err()
 			}
-			case <- statusCh:
-
+			return 1 , usage , err
+		case status := <- statusCh:
+			exitCode = int(status.StatusCode)
+			if status.Error != nil {
+				err = errors.New(status.Error.Message)
+			}
+		case <- ctx.Done():
+			return 1 , usage , ctx.Err()
 	}
-	out , err := d.client.ContainerLogs(context.Background(),resp.ID,types.ContainerLogsOptions{ShowStderr: true,ShowStdout: true})
-	if err != nil {
-		return nil , nil , err
+	<- copied
+	inspect , inspectErr := d.client.ContainerInspect(context.Background(),resp.ID)
+	if inspectErr == nil && inspect.State != nil && inspect.State.OOMKilled {
+		return exitCode , usage , ErrOOMKilled
 	}
-	io.Copy(Out,out)
-	io.Copy(Err,out)
-	err = nil
-	return Out , Err , err
+	return exitCode , usage , err
 }
 
 func (d *DockerManager) StopContainer(containerId string) error {
@@ -0,0 +1,218 @@
+package executors
+
+import (
+	"bioflows/managers"
+	"bioflows/models"
+	"bioflows/resolver"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	CACHE_LOCATION = "location"
+	CACHE_CONFIG = "config"
+)
+
+/*
+	StepCache restores the results of a step from a previous successful run whenever the step is about to run again
+	with exactly the same rendered command, image, tool definition and input files.
+	The entries are kept in the state manager under bioflows/cache/<key>.
+ */
+type StepCache struct {
+	stateManager managers.StateManager
+}
+
+func NewStepCache(stateManager managers.StateManager) *StepCache {
+	return &StepCache{stateManager: stateManager}
+}
+
+/*
+	Key hashes everything which decides the results of a tool: its rendered command and environment variables (NAME=value, sorted),
+	its image, its definition and the digests of its file, directory and glob inputs.
+ */
+func (c *StepCache) Key(command string , env []string , imageId string , tool *models.Tool , toolConfig models.FlowConfig) (string,error) {
+	// Execution directives do not change the results of a tool, so they are left out of the key
+	toolDefinition := *tool
+	toolDefinition.Caps = nil
+	toolDefinition.Retry = nil
+	toolDefinition.Timeout = ""
+	toolDefinition.Cache = nil
+	toolDefinition.Notification = nil
+	definition , err := json.Marshal(toolDefinition)
+	if err != nil {
+		return "" , err
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash,"command:%s\nimage:%s\ntool:%s\n",command,imageId,definition)
+	for _ , variable := range env {
+		fmt.Fprintf(hash,"env:%s\n",variable)
+	}
+	for _ , param := range tool.Inputs {
+		paramType := param.GetType()
+		if paramType != models.PARAM_TYPE_FILE && paramType != models.PARAM_TYPE_DIR && paramType != models.PARAM_TYPE_DIRECTORY &&
+			paramType != models.PARAM_TYPE_GLOB {
+			continue
+		}
+		value , ok := toolConfig[param.Name]
+		if !ok || value == nil || len(fmt.Sprintf("%v",value)) == 0 {
+			continue
+		}
+		paths , err := getInputPaths(paramType,value)
+		if err != nil {
+			return "" , err
+		}
+		for _ , path := range paths {
+			digest , err := digestPath(path)
+			if err != nil {
+				return "" , err
+			}
+			fmt.Fprintf(hash,"input:%s:%s\n",param.Name,digest)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)) , nil
+}
+
+// Lookup returns the output location and the configuration saved for the given key by a previous successful run
+func (c *StepCache) Lookup(key string) (string,models.FlowConfig,bool) {
+	data , err := c.stateManager.GetStateByID(resolver.ResolveCacheKey(key))
+	if err != nil || data == nil {
+		return "" , nil , false
+	}
+	entry , ok := data.(map[string]interface{})
+	if !ok {
+		return "" , nil , false
+	}
+	savedConfig , ok := entry[CACHE_CONFIG].(map[string]interface{})
+	if !ok {
+		return "" , nil , false
+	}
+	location := fmt.Sprintf("%v",entry[CACHE_LOCATION])
+	if _ , err := os.Stat(location); err != nil {
+		// The outputs of the previous run are gone, there is nothing to restore
+		return "" , nil , false
+	}
+	return location , models.FlowConfig(savedConfig) , true
+}
+
+func (c *StepCache) Store(key string , location string , toolConfig models.FlowConfig) error {
+	return c.stateManager.SetStateByID(resolver.ResolveCacheKey(key),map[string]interface{}{
+		CACHE_LOCATION: location,
+		CACHE_CONFIG: toolConfig.GetAsMap(),
+	})
+}
+
+// Restore copies the outputs of a previous run into the given output directory, files which already exist are kept
+func (c *StepCache) Restore(location string , outputDir string) error {
+	if filepath.Clean(location) == filepath.Clean(outputDir) {
+		return nil
+	}
+	return filepath.Walk(location, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative , err := filepath.Rel(location,path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(outputDir,relative)
+		if info.IsDir() {
+			return os.MkdirAll(target,info.Mode())
+		}
+		if _ , err := os.Stat(target); err == nil {
+			return nil
+		}
+		return copyFile(path,target,info.Mode())
+	})
+}
+
+// getInputPaths returns the paths an input refers to, a list of paths or a glob pattern which is expanded in sorted order
+func getInputPaths(paramType string , value interface{}) ([]string,error) {
+	paths := make([]string,0)
+	if elements , ok := value.([]interface{}); ok {
+		for _ , element := range elements {
+			paths = append(paths,fmt.Sprintf("%v",element))
+		}
+		return paths , nil
+	}
+	if paramType != models.PARAM_TYPE_GLOB {
+		return append(paths,fmt.Sprintf("%v",value)) , nil
+	}
+	matches , err := filepath.Glob(fmt.Sprintf("%v",value))
+	if err != nil {
+		return nil , err
+	}
+	sort.Strings(matches)
+	return matches , nil
+}
+
+// digestPath hashes the contents of a file, or the names and contents of all files inside a directory
+func digestPath(path string) (string,error) {
+	info , err := os.Stat(path)
+	if err != nil {
+		return "" , err
+	}
+	hash := sha256.New()
+	if !info.IsDir() {
+		err = hashFile(hash,path)
+		if err != nil {
+			return "" , err
+		}
+		return hex.EncodeToString(hash.Sum(nil)) , nil
+	}
+	files := make([]string,0)
+	err = filepath.Walk(path, func(current string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files,current)
+		}
+		return nil
+	})
+	if err != nil {
+		return "" , err
+	}
+	sort.Strings(files)
+	for _ , file := range files {
+		relative , _ := filepath.Rel(path,file)
+		fmt.Fprintf(hash,"%s\n",relative)
+		err = hashFile(hash,file)
+		if err != nil {
+			return "" , err
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)) , nil
+}
+
+func hashFile(writer io.Writer , path string) error {
+	file , err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_ , err = io.Copy(writer,file)
+	return err
+}
+
+func copyFile(source string , target string , mode os.FileMode) error {
+	in , err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out , err := os.OpenFile(target,os.O_CREATE|os.O_WRONLY|os.O_TRUNC,mode)
+	if err != nil {
+		return err
+	}
+	_ , err = io.Copy(out,in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -8,6 +8,7 @@ import (
 	"bioflows/models/pipelines"
 	"bioflows/resolver"
 	"bioflows/scripts"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/aidarkhanov/nanoid"
@@ -17,6 +18,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 type DagExecutor struct {
@@ -28,15 +30,31 @@ type DagExecutor struct {
 	containerConfig *models.ContainerConfig
 	scheduler *DagScheduler
 	exprManager *expr.ExprManager
-	rankedList [][]*dag.Vertex
 	basePath string
 	instanceId string
 	finalStatus bool
 	explain bool
+	ctx context.Context
+	// cancel stops all running steps of the current pipeline only, it is used by the fail_fast failure policy
+	cancel context.CancelFunc
+	// failedStep is the step which has stopped the pipeline according to its failure policy
+	failedStep string
+	budget *ResourceBudget
+	cache *StepCache
+	images *ImagePuller
+	// startTime and endTime are when the pipeline has started and finished running
+	startTime time.Time
+	endTime time.Time
 	// this bucket represents all errors that might have been encountered during the execution of the current DagExecutor
 	errors []error
+	// errorsMutex guards errors, the steps add their errors from their own goroutines
+	errorsMutex sync.Mutex
 }
 
+// TimedOut tells whether the pipeline has been stopped by its own or an enclosing timeout
+func (p *DagExecutor) TimedOut() bool {
+	return p.ctx != nil && p.ctx.Err() == context.DeadlineExceeded
+}
 func (p *DagExecutor) GetFinalStatus() bool {
 	return p.finalStatus
 }
@@ -55,6 +73,10 @@ func (p *DagExecutor) init() error {
 	p.instanceId = instanceId
 	return nil
 }
+// SetInstanceId reuses the instance ID of a previous run, so the steps which have already succeeded are skipped
+func (p *DagExecutor) SetInstanceId(instanceId string) {
+	p.instanceId = instanceId
+}
 func (p *DagExecutor) GetInstanceId() string {
 	return p.instanceId
 }
@@ -67,6 +89,14 @@ func (p *DagExecutor) SetBasePath(basePath string) {
 func (p *DagExecutor) SetContainerConfig(containerConfig *models.ContainerConfig) {
 	p.containerConfig = containerConfig
 }
+// SetImagePuller shares the images pulled by a parent executor with the current one
+func (p *DagExecutor) SetImagePuller(images *ImagePuller) {
+	p.images = images
+}
+// SetResourceBudget shares the resources budget of a parent executor with the current one
+func (p *DagExecutor) SetResourceBudget(budget *ResourceBudget) {
+	p.budget = budget
+}
 func (p *DagExecutor) SetContext(c *managers.ContextManager) {
 	p.contextManager = c
 }
@@ -81,6 +111,13 @@ func (p *DagExecutor) copyParentParamsInto(step *pipelines.BioPipeline) {
 		}
 	}
 }
+// copyParentEnvInto makes the step inherit the environment variables and the pull policy of its parent pipeline
+func (p *DagExecutor) copyParentEnvInto(step *pipelines.BioPipeline) {
+	step.Env = models.MergeEnv(p.parentPipeline.Env,step.Env)
+	if len(step.PullPolicy) == 0 {
+		step.PullPolicy = p.parentPipeline.PullPolicy
+	}
+}
 func (p *DagExecutor) GetContext() *managers.ContextManager {
 	return p.contextManager
 }
@@ -103,7 +140,7 @@ func (p *DagExecutor) GetPipelineOutput(pipelineId *string) models.FlowConfig {
 }
 
 
-func (p DagExecutor) SetPipelineGeneralConfig(b *pipelines.BioPipeline,originalConfig *models.FlowConfig) {
+func (p *DagExecutor) SetPipelineGeneralConfig(b *pipelines.BioPipeline,originalConfig *models.FlowConfig) {
 	// Read the pipeline general configuration section
 	if b.Config != nil && len(b.Config) > 0 {
 		internalConfig := make(map[string]interface{})
@@ -129,13 +166,14 @@ func (p *DagExecutor) CheckStatus(pipelineId string , step pipelines.BioPipeline
 	if toolData != nil {
 		data := toolData.(map[string]interface{})
 		if ok, found := data["status"]; found && ok.(bool){
-			status = DONT_RUN
+			return ALREADY_RUN
 		}
 	}
 	//Check that all dependent steps have run successfully
-	if len(step.Depends) > 0 {
-		depends := strings.Split(step.Depends,",")
+	depends := step.GetDepends()
+	if len(depends) > 0 {
 		result := true
+		skipped := false
 		for _ , v := range depends {
 			toolName := resolver.ResolveToolKey(v,pipelineId)
 			data , _ := p.GetContext().GetStateManager().GetStateByID(toolName)
@@ -143,6 +181,11 @@ func (p *DagExecutor) CheckStatus(pipelineId string , step pipelines.BioPipeline
 				toolConfig := data.(map[string]interface{})
 				if statusVar , found := toolConfig["status"]; !found {
 					status = SHOULD_QUEUE
+				}else if toolConfig[models.STEP_STATE_KEY] == models.STEP_STATE_SKIPPED {
+					// A skipped dependency either satisfies the current step or skips it as well
+					skipped = skipped || !step.AcceptsSkipped()
+				}else if toolConfig[models.STEP_ALLOWED_FAILURE_KEY] == true {
+					// The dependency has failed but it is allowed to fail
 				}else{
 					result = result && (statusVar.(bool))
 				}
@@ -153,6 +196,8 @@ func (p *DagExecutor) CheckStatus(pipelineId string , step pipelines.BioPipeline
 		}
 		if !result{
 			status = DONT_RUN
+		}else if skipped && status == SHOULD_RUN {
+			status = SHOULD_SKIP
 		}
 	}
 	return status
@@ -164,6 +209,8 @@ func (p *DagExecutor) Setup(config models.FlowConfig) error {
 		return err
 	}
 	p.scheduler = &DagScheduler{}
+	p.budget = NewResourceBudgetFromConfig(config)
+	p.images = NewImagePuller()
 	p.exprManager = &expr.ExprManager{}
 	p.transformations = make([]TransformCall,0)
 	p.contextManager = &managers.ContextManager{}
@@ -173,6 +220,7 @@ func (p *DagExecutor) Setup(config models.FlowConfig) error {
 		return err
 	}
 	p.planManager.SetContextManager(p.contextManager)
+	p.cache = NewStepCache(p.contextManager.GetStateManager())
 	p.createLogFile(config)
 	return p.planManager.Setup(config)
 }
@@ -197,6 +245,24 @@ func (p *DagExecutor) Log(logs ...interface{}) {
 }
 
 func (p *DagExecutor) Run(b *pipelines.BioPipeline,config models.FlowConfig) error {
+	return p.RunContext(context.Background(),b,config)
+}
+// RunContext runs the pipeline until it finishes or the given context is done,
+// in the latter case all running steps are killed and recorded as cancelled.
+func (p *DagExecutor) RunContext(ctx context.Context,b *pipelines.BioPipeline,config models.FlowConfig) error {
+	p.ctx = ctx
+	p.startTime = time.Now()
+	timeout , err := b.GetTimeout()
+	if err != nil {
+		return fmt.Errorf("Invalid timeout for (%s): %s",b.Name,err.Error())
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		p.ctx , cancel = context.WithTimeout(ctx,timeout)
+		defer cancel()
+	}
+	p.ctx , p.cancel = context.WithCancel(p.ctx)
+	defer p.cancel()
 	p.SetPipelineGeneralConfig(b,&config)
 	var finalError error
 	defer func() error{
@@ -215,13 +281,49 @@ func (p *DagExecutor) Run(b *pipelines.BioPipeline,config models.FlowConfig) err
 		return finalError
 	}()
 	p.parentPipeline = b
-	finalError = p.runLocal(b,config)
+	p.notify(b,models.NOTIFY_ON_START,config,config)
+	if !p.explain {
+		finalError = p.prefetchImages(b,config)
+	}
+	if finalError == nil {
+		finalError = p.runLocal(b,config)
+	}
+	p.endTime = time.Now()
+	pipelineConfig := models.FlowConfig{}
+	pipelineConfig.Fill(config)
+	pipelineConfig["status"] = p.finalStatus && finalError == nil
+	pipelineConfig[models.STEP_STATE_KEY] = models.STEP_STATE_SUCCEEDED
+	if pipelineConfig["status"] != true {
+		pipelineConfig[models.STEP_STATE_KEY] = models.STEP_STATE_FAILED
+	}
+	p.notify(b,getNotificationEvent(pipelineConfig),pipelineConfig,config)
 	p.Log(fmt.Sprintf("Workflow: (%s) has finished....",b.Name))
 	p.addError(finalError)
 	//Finally add all errors
 	return p.GetAllErrors()
 }
+// GetStepState returns the recorded state of the given step of the current pipeline, nil if it has not run
+func (p *DagExecutor) GetStepState(step pipelines.BioPipeline) interface{} {
+	toolKey := resolver.ResolveToolKey(step.ID,p.GetPipelineKey())
+	data , _ := p.GetContext().GetStateManager().GetStateByID(toolKey)
+	return data
+}
+// GetUsage sums up the resources used by all steps of the pipeline, the wall time is how long the pipeline took
+func (p *DagExecutor) GetUsage() models.ResourceUsage {
+	usage := models.ResourceUsage{WallTime: p.endTime.Sub(p.startTime)}
+	if p.parentPipeline == nil {
+		return usage
+	}
+	for _ , step := range p.parentPipeline.Steps {
+		if stepUsage , ok := models.GetUsageFromState(p.GetStepState(step)); ok {
+			usage.Combine(stepUsage)
+		}
+	}
+	return usage
+}
 func (p *DagExecutor) GetAllErrors() error {
+	p.errorsMutex.Lock()
+	defer p.errorsMutex.Unlock()
 	var errString string = ""
 	if len(p.errors) > 0 {
 		for _ , err := range p.errors {
@@ -241,15 +343,16 @@ func (p *DagExecutor) runLocal(b *pipelines.BioPipeline, config models.FlowConfi
 	if err != nil {
 		return err
 	}
-	p.rankedList , err = p.scheduler.Rank(b,graph)
+	readyList := p.scheduler.Prepare(b,graph)
+	if len(readyList) == 0 && len(b.Steps) > 0 {
+		return errors.New("Failed to find the starting steps of the current pipeline. Aborting....")
+	}
+	// evaluate current pipeline parameters
+	err = p.evaluateParameters(b,config)
 	if err != nil {
+		p.Log(fmt.Sprintf("Evaluating Parameters (%s) Error : %s",b.Name,err.Error()))
 		return err
 	}
-	if p.rankedList == nil {
-		return errors.New("Failed to rank the current pipeline. Aborting....")
-	}
-	// evaluate current pipeline parameters
-	p.evaluateParameters(b,config)
 	// try to execute any before scripts
 	err = p.executeBeforeScripts(b,config)
 	if err != nil {
@@ -262,16 +365,47 @@ func (p *DagExecutor) runLocal(b *pipelines.BioPipeline, config models.FlowConfi
 			p.Log(fmt.Sprintf("Executing Script (%s) Error : %s",b.Name,err.Error()))
 		}
 	}()
-	for _ , sublist := range p.rankedList {
-		wg := sync.WaitGroup{}
-		for _ , node := range sublist {
-			if node == nil {
-				continue
+	policy , err := b.GetFailurePolicy()
+	if err != nil {
+		return err
+	}
+	// Every step is launched as soon as all of its parents have finished
+	done := make(chan *dag.Vertex)
+	running := 0
+	for _ , node := range readyList {
+		running++
+		go p.execute(config,node,done)
+	}
+	for running > 0 {
+		finished := <- done
+		running--
+		if p.checkStep(finished) && len(p.failedStep) == 0 && policy != pipelines.FAILURE_POLICY_CONTINUE {
+			p.failedStep = finished.Value.(pipelines.BioPipeline).Name
+			p.Log(fmt.Sprintf("Workflow (%s): Flow %s has failed, stopping the workflow (%s)....",b.Name,p.failedStep,policy))
+			if policy == pipelines.FAILURE_POLICY_FAIL_FAST {
+				p.cancel()
 			}
-			wg.Add(1)
-			go p.execute(config,node,&wg)
 		}
-		wg.Wait()
+		for _ , node := range p.scheduler.Complete(finished) {
+			running++
+			if len(p.failedStep) > 0 {
+				go p.cancelStep(config,node,done)
+			}else{
+				go p.execute(config,node,done)
+			}
+		}
+	}
+	if p.ctx.Err() == context.DeadlineExceeded {
+		p.finalStatus = false
+		return fmt.Errorf("Workflow (%s) has timed out",b.Name)
+	}
+	if len(p.failedStep) > 0 {
+		p.finalStatus = false
+		return fmt.Errorf("Workflow (%s) has been stopped after the failure of %s",b.Name,p.failedStep)
+	}
+	if p.ctx.Err() != nil {
+		p.finalStatus = false
+		return fmt.Errorf("Workflow (%s) has been cancelled: %s",b.Name,p.ctx.Err().Error())
 	}
 	return nil
 }
@@ -287,42 +421,55 @@ func (p *DagExecutor) prepareConfig(b *pipelines.BioPipeline,config models.FlowC
 		return tempConfig
 	}
 	tempConfig.Fill(pipelineConfig)
+	tempConfig[config2.WF_BF_STEPS] = stepOutputs(pipelineConfig)
 	// ***** End: Get Parent Pipeline Configuration from KV Store ********
 	return tempConfig
 }
+// stepOutputs exposes the state of every step so it could be referenced as {{steps.<step id>.outputs.<output name>}}
+func stepOutputs(pipelineConfig models.FlowConfig) map[string]interface{} {
+	steps := make(map[string]interface{})
+	for stepId , state := range pipelineConfig {
+		steps[stepId] = map[string]interface{}{
+			"outputs": state,
+		}
+	}
+	return steps
+}
+// getStepReferences returns the outputs of the steps of the current pipeline which have already run
+func (p *DagExecutor) getStepReferences() models.FlowConfig {
+	references := models.FlowConfig{}
+	pipelineConfig , err := p.GetContext().GetStateManager().GetPipelineState(p.GetPipelineKey())
+	if err != nil {
+		pipelineConfig = models.FlowConfig{}
+	}
+	references[config2.WF_BF_STEPS] = stepOutputs(pipelineConfig)
+	return references
+}
+// GetStepOutputDirectory returns the directory of the given step inside the output directory, laid out by the step_dir_layout of the workflow
 func (p *DagExecutor) GetStepOutputDirectory(config models.FlowConfig , currentFlow *pipelines.BioPipeline) (string,error) {
-	self_dir := strings.Join([]string{p.parentPipeline.ID,currentFlow.ID},"_")
 	workflowOutputDir , ok := config[config2.WF_INSTANCE_OUTDIR]
 	if !ok {
 		err := fmt.Errorf("Output_dir configuration parameter is not set. Please set this variable and try again.")
 		return "" , err
 	}
-	return strings.Join([]string{fmt.Sprintf("%v",workflowOutputDir),self_dir},string(os.PathSeparator)) , nil
+	return GetStepDir(fmt.Sprintf("%v",workflowOutputDir),config,p.parentPipeline.ID,currentFlow.ID) , nil
 }
-func (p *DagExecutor) evaluateParameters(step *pipelines.BioPipeline,config models.FlowConfig) {
+func (p *DagExecutor) evaluateParameters(step *pipelines.BioPipeline,config models.FlowConfig) error {
 	//Evaluate current Step inputs
 	selfDir , err := p.GetStepOutputDirectory(config,step)
 	if err != nil {
-		return
+		return nil
 	}
 	config["self_dir"] = selfDir
 	config["location"] = selfDir
-	if step.Inputs != nil && len(step.Inputs) > 0 {
-		for _ , param := range step.Inputs {
-			if param.Value == nil {
-				if _ , ok := config[param.Name] ; !ok {
-					config[param.Name] = ""
-				}
-				continue
-			}
-			config[param.Name] = p.exprManager.Render(param.GetParamValue(),config)
-		}
-	}
-	// Adding the current step Config internal parameters
-	if step.Config != nil && len(step.Config) > 0 {
-		for _ , param := range step.Config{
-			config[param.Name] = p.exprManager.Render(param.GetParamValue(),config)
-		}
+	references := p.getStepReferences()
+	// Inputs and the current step Config internal parameters are coerced to their types and validated
+	params := make([]models.Parameter,0,len(step.Inputs)+len(step.Config))
+	params = append(params,step.Inputs...)
+	params = append(params,step.Config...)
+	err = ResolveParameters(params,config,false,references)
+	if err != nil {
+		return err
 	}
 	//Evaluate current Step outputs
 	if step.Outputs != nil && len(step.Outputs) > 0 {
@@ -333,9 +480,10 @@ func (p *DagExecutor) evaluateParameters(step *pipelines.BioPipeline,config mode
 				}
 				continue
 			}
-			config[param.Name] = p.exprManager.Render(param.GetParamValue(),config)
+			config[param.Name] = p.exprManager.Render(param.GetParamValue(),config,references)
 		}
 	}
+	return nil
 }
 func (p *DagExecutor) executeBeforeScripts(step *pipelines.BioPipeline , config models.FlowConfig) error{
 
@@ -401,15 +549,82 @@ func (p *DagExecutor) executeAfterScripts (step *pipelines.BioPipeline,config mo
 	return nil
 }
 func (p *DagExecutor) reportFailure(toolKey string , flowConfig models.FlowConfig) error{
+	return p.reportState(toolKey,flowConfig,models.STEP_STATE_FAILED,1)
+}
+func (p *DagExecutor) reportCancelled(toolKey string , config models.FlowConfig) error {
+	flowConfig := models.FlowConfig{}
+	flowConfig.Fill(config)
 	flowConfig["status"] = false
 	flowConfig["exitCode"] = 1
+	flowConfig[models.STEP_STATE_KEY] = models.STEP_STATE_CANCELLED
+	if p.ctx.Err() == context.DeadlineExceeded {
+		flowConfig[models.STEP_STATE_KEY] = models.STEP_STATE_TIMEOUT
+	}
 	err := p.contextManager.SaveState(toolKey,flowConfig.GetAsMap())
 	if err != nil {
 		fmt.Println(fmt.Sprintf("Received Error: %s",err.Error()))
 		return err
 	}
 	return nil
-
+}
+func (p *DagExecutor) reportSkipped(toolKey string , config models.FlowConfig) error {
+	return p.reportState(toolKey,config,models.STEP_STATE_SKIPPED,0)
+}
+func (p *DagExecutor) reportBlocked(toolKey string , config models.FlowConfig) error {
+	return p.reportState(toolKey,config,models.STEP_STATE_BLOCKED,1)
+}
+// reportState records a step which has not been executed together with the reason
+func (p *DagExecutor) reportState(toolKey string , config models.FlowConfig , state string , exitCode int) error {
+	flowConfig := models.FlowConfig{}
+	flowConfig.Fill(config)
+	flowConfig["status"] = false
+	flowConfig["exitCode"] = exitCode
+	flowConfig[models.STEP_STATE_KEY] = state
+	err := p.contextManager.SaveState(toolKey,flowConfig.GetAsMap())
+	if err != nil {
+		fmt.Println(fmt.Sprintf("Received Error: %s",err.Error()))
+		return err
+	}
+	return nil
+}
+// checkStep updates the final status of the pipeline with the outcome of the given finished step,
+// it returns true only if the step itself has failed and it is not allowed to fail.
+func (p *DagExecutor) checkStep(vertex *dag.Vertex) bool {
+	step := vertex.Value.(pipelines.BioPipeline)
+	toolKey := resolver.ResolveToolKey(step.ID,p.GetPipelineKey())
+	data , _ := p.GetContext().GetStateManager().GetStateByID(toolKey)
+	state , _ := data.(map[string]interface{})
+	if status , ok := state["status"].(bool); ok && status {
+		return false
+	}
+	switch state[models.STEP_STATE_KEY] {
+	case models.STEP_STATE_SKIPPED:
+		return false
+	case models.STEP_STATE_CANCELLED , models.STEP_STATE_BLOCKED:
+		p.finalStatus = false
+		return false
+	}
+	if step.AllowsFailure() {
+		p.Log(fmt.Sprintf("Flow: %s has failed but it is allowed to fail, continuing....",step.Name))
+		// Let the dependent steps know that this failure does not block them
+		allowed := models.FlowConfig{}
+		allowed.Fill(state)
+		allowed[models.STEP_ALLOWED_FAILURE_KEY] = true
+		p.contextManager.SaveState(toolKey,allowed.GetAsMap())
+		return false
+	}
+	p.finalStatus = false
+	return true
+}
+// cancelStep records a step which will never be launched because the pipeline has been stopped by its failure policy
+func (p *DagExecutor) cancelStep(config models.FlowConfig,vertex *dag.Vertex,done chan<- *dag.Vertex) {
+	defer func(){
+		done <- vertex
+	}()
+	currentFlow := vertex.Value.(pipelines.BioPipeline)
+	toolKey := resolver.ResolveToolKey(currentFlow.ID,p.GetPipelineKey())
+	p.Log(fmt.Sprintf("Flow: %s has been cancelled after the failure of %s.",currentFlow.Name,p.failedStep))
+	p.reportState(toolKey,config,models.STEP_STATE_CANCELLED,1)
 }
 func (p *DagExecutor) getAttachableVolumes(step *pipelines.BioPipeline) ([]models.Parameter,error) {
 	attachable := make(map[string]models.Parameter)
@@ -441,22 +656,143 @@ func (p *DagExecutor) runInloopScripts(InLineScripts []models.Script,config mode
 		_ = scriptManager.RunScript(script,config)
 	}
 }
-func (p *DagExecutor) execute(config models.FlowConfig,vertex *dag.Vertex,wg *sync.WaitGroup) {
-	defer wg.Done()
+// runToolIteration runs a single element of a looping tool step and records its state under its own key
+func (p *DagExecutor) runToolIteration(currentFlow pipelines.BioPipeline,toolKey string,config models.FlowConfig,idx int,binding models.FlowConfig) (models.FlowConfig,bool) {
+	executor := ToolExecutor{}
+	executor.SetBasePath(toolKey)
+	executor.SetPipelineName(p.parentPipeline.ID)
+	executor.SetContainerConfiguration(p.containerConfig)
+	toolInstance := &models.ToolInstance{
+		WorkflowID: p.parentPipeline.ID,
+		WorkflowName: p.parentPipeline.Name,
+		Tool:currentFlow.ToTool(),
+	}
+	toolInstance.Prepare()
+	// The conditions have already been evaluated and the notifications are sent for the whole step
+	toolInstance.Conditions = nil
+	toolInstance.Notification = nil
+	generalConfig := p.prepareConfig(p.parentPipeline,config)
+	generalConfig.Fill(binding)
+	generalConfig[fmt.Sprintf("loop_index")] = idx
+	// RunScript the given tool
+	volumes , err := p.getAttachableVolumes(&currentFlow)
+	if err != nil {
+		p.Log(fmt.Sprintf("Received Error : %s",err.Error()))
+		return nil , false
+	}
+	executor.SetAttachableVolumes(volumes)
+	executor.SetResourceBudget(p.budget)
+	executor.SetStepCache(p.cache)
+	executor.SetImagePuller(p.images)
+	// Run InLoop Scripts first
+	inlineScripts := currentFlow.GetInLoopScripts()
+	if len(inlineScripts) > 0{
+		p.runInloopScripts(inlineScripts,generalConfig)
+	}
+	executor.SetExplain(p.explain)
+	toolInstanceFlowConfig , err := executor.RunContext(p.ctx,toolInstance,generalConfig)
+	if err != nil {
+		p.Log(fmt.Sprintf("Received Error : %s",err.Error()))
+	}
+	if toolInstanceFlowConfig == nil {
+		return nil , false
+	}
+	status , _ := toolInstanceFlowConfig["status"].(bool)
+	err = p.contextManager.SaveState(executor.GetToolKey(),toolInstanceFlowConfig.GetAsMap())
+	if err != nil {
+		fmt.Println(fmt.Sprintf("Received Error: %s",err.Error()))
+		return toolInstanceFlowConfig , false
+	}
+	return toolInstanceFlowConfig , status
+}
+// runPipelineIteration runs a single element of a looping nested pipeline step and records its state under its own key
+func (p *DagExecutor) runPipelineIteration(currentFlow pipelines.BioPipeline,toolKey string,config models.FlowConfig,idx int,binding models.FlowConfig) (models.FlowConfig,bool) {
+	// The notifications are sent for the whole step
+	currentFlow.Notification = nil
+	nestedPipelineExecutor := DagExecutor{}
+	nestedPipelineExecutor.SetContainerConfig(p.containerConfig)
+	nestedPipelineConfig := models.FlowConfig{}
+	pipelineConfig := p.prepareConfig(&currentFlow,config)
+	nestedPipelineConfig.Fill(config)
+	nestedPipelineConfig.Fill(pipelineConfig)
+	nestedPipelineExecutor.Setup(nestedPipelineConfig)
+	nestedPipelineExecutor.SetResourceBudget(p.budget)
+	nestedPipelineExecutor.SetImagePuller(p.images)
+	nestedPipelineExecutor.SetBasePath(toolKey)
+	nestedPipelineConfig.Fill(binding)
+	nestedPipelineConfig[fmt.Sprintf("loop_index")] = idx
+	inlineScripts := currentFlow.GetInLoopScripts()
+	if len(inlineScripts) > 0{
+		p.runInloopScripts(inlineScripts,nestedPipelineConfig)
+	}
+	err := nestedPipelineExecutor.RunContext(p.ctx,&currentFlow,nestedPipelineConfig)
+	if err != nil {
+		nestedPipelineExecutor.Log(err.Error())
+	}
+	pipeConfig := nestedPipelineExecutor.GetPipelineOutput(nil)
+	pipelineKeyInAloop := nestedPipelineExecutor.GetPipelineKey()
+	err = p.contextManager.SaveState(pipelineKeyInAloop,pipeConfig.GetAsMap())
+	if err != nil {
+		fmt.Println(fmt.Sprintf("Received Error: %s",err.Error()))
+	}
+	// The outputs of the step are evaluated against the results of this iteration
+	iterationConfig := models.FlowConfig{}
+	iterationConfig.Fill(nestedPipelineConfig)
+	iterationConfig.Fill(pipeConfig)
+	iterationOutputs := models.FlowConfig{}
+	for _ , param := range currentFlow.Outputs {
+		if param.Value == nil {
+			continue
+		}
+		iterationOutputs[param.Name] = p.exprManager.Render(param.GetParamValue(),iterationConfig)
+	}
+	iterationOutputs[models.STEP_USAGE_KEY] = nestedPipelineExecutor.GetUsage().ToMap()
+	return iterationOutputs , nestedPipelineExecutor.GetFinalStatus()
+}
+func (p *DagExecutor) execute(config models.FlowConfig,vertex *dag.Vertex,done chan<- *dag.Vertex) {
+	defer func(){
+		done <- vertex
+	}()
 	currentFlow := vertex.Value.(pipelines.BioPipeline)
 	PreprocessPipeline(&currentFlow,config,p.transformations...)
 	toolKey := resolver.ResolveToolKey(currentFlow.ID,p.GetPipelineKey())
 	//pipelineKey := resolver.ResolvePipelineKey(p.parentPipeline.ID)
 	status := p.CheckStatus(p.GetPipelineKey(),currentFlow)
+	if p.ctx.Err() != nil {
+		p.Log(fmt.Sprintf("Flow: %s has been cancelled.",currentFlow.Name))
+		p.reportCancelled(toolKey,config)
+		return
+	}
 	switch status {
 	case SHOULD_RUN:
 		p.copyParentParamsInto(&currentFlow)
+		p.copyParentEnvInto(&currentFlow)
 		// Step 1: Evaluate input parameters and output parameters for the current step before executing it
-		p.evaluateParameters(&currentFlow,config)
+		err := p.evaluateParameters(&currentFlow,config)
+		if err != nil {
+			p.Log(fmt.Sprintf("Evaluating Parameters (%s) Error : %s",currentFlow.Name,err.Error()))
+			p.addError(err)
+			p.reportFailure(toolKey,config)
+			return
+		}
+		// The conditions of the step decide whether it should run at all
+		conditionsManager := &scripts.JSScriptManager{}
+		satisfied , err := conditionsManager.EvaluateConditions(currentFlow.Conditions,config)
+		if err != nil {
+			p.Log(fmt.Sprintf("Evaluating Conditions (%s) Error : %s",currentFlow.Name,err.Error()))
+			p.reportFailure(toolKey,config)
+			return
+		}
+		if !satisfied {
+			p.Log(fmt.Sprintf("Flow: %s has been skipped, its conditions are not satisfied.",currentFlow.Name))
+			p.reportSkipped(toolKey,config)
+			return
+		}
 		//Step 2: Try to execute before scripts first
-		err := p.executeBeforeScripts(&currentFlow,config)
+		err = p.executeBeforeScripts(&currentFlow,config)
 		if err != nil {
 			p.Log(fmt.Sprintf("Executing Scripts (%s) Error : %s",currentFlow.Name,err.Error()))
+			p.reportFailure(toolKey,config)
 			return
 		}
 		defer func(){
@@ -468,84 +804,23 @@ func (p *DagExecutor) execute(config models.FlowConfig,vertex *dag.Vertex,wg *sy
 		if currentFlow.IsTool() {
 			// It is a tool
 			if currentFlow.IsLoop() {
-				// Get the loop variable
-				if len(currentFlow.LoopVar) == 0 {
-					p.Log(fmt.Sprintf("Tool is loop but no loop variable has been defined.. aborting..."))
+				// Get the bindings of the loop variables of every iteration
+				bindings , err := models.Scatter(config,currentFlow.GetLoopVars(),currentFlow.ScatterMode)
+				if err != nil {
+					p.Log(fmt.Sprintf("Failing Tool : %s, %s",currentFlow.Name,err.Error()))
+					p.addError(err)
 					p.reportFailure(toolKey,config)
 					return
 				}
-				// Get Loop Variable name
-				if loop_elements , ok := config[currentFlow.LoopVar] ; ok {
-					if elements , islist := loop_elements.([]interface{}); islist {
-						stepTruth := true
-						for idx , el := range elements {
-							executor := ToolExecutor{}
-							executor.SetBasePath(toolKey)
-							executor.SetPipelineName(p.parentPipeline.ID)
-							executor.SetContainerConfiguration(p.containerConfig)
-							toolInstance := &models.ToolInstance{
-								WorkflowID: p.parentPipeline.ID,
-								WorkflowName: p.parentPipeline.Name,
-								Tool:currentFlow.ToTool(),
-							}
-							toolInstance.Prepare()
-							generalConfig := p.prepareConfig(p.parentPipeline,config)
-							generalConfig[fmt.Sprintf("%s_item",currentFlow.LoopVar)] = el
-							generalConfig[fmt.Sprintf("loop_index")] = idx
-							// RunScript the given tool
-							volumes , err := p.getAttachableVolumes(&currentFlow)
-							if err != nil {
-								executor.Log(fmt.Sprintf("Received Error : %s",err.Error()))
-								return
-							}
-							executor.SetAttachableVolumes(volumes)
-							// Run InLoop Scripts first
-							inlineScripts := currentFlow.GetInLoopScripts()
-							if len(inlineScripts) > 0{
-								p.runInloopScripts(inlineScripts,generalConfig)
-							}
-							executor.SetExplain(p.explain)
-							toolInstanceFlowConfig , err := executor.Run(toolInstance,generalConfig)
-							if err != nil {
-
-								executor.Log(fmt.Sprintf("Received Error : %s",err.Error()))
-							}
-							if toolInstanceFlowConfig != nil {
-								stepTruth = stepTruth && toolInstanceFlowConfig["status"].(bool)
-
-								if idx < len(elements) - 1{
-									toolInstanceFlowConfig["status"] = false
-								}else{
-									toolInstanceFlowConfig["status"] = stepTruth
-								}
-								toolKeyInAloop := executor.GetToolKey()
-								err = p.contextManager.SaveState(toolKeyInAloop,toolInstanceFlowConfig.GetAsMap())
-								if err != nil {
-									fmt.Println(fmt.Sprintf("Received Error: %s",err.Error()))
-									return
-								}
-							}
-						}
-						config["status"] = stepTruth
-						config["exitCode"] = 0
-						p.finalStatus = p.finalStatus && stepTruth
-						err = p.contextManager.SaveState(toolKey,config.GetAsMap())
-						if err != nil {
-							fmt.Println(fmt.Sprintf("Received Error: %s",err.Error()))
-							return
-						}
-
-					}else{
-						// The Loop variable contains non-array type data , i.e. it is not an array
-						p.reportFailure(toolKey,config)
-						p.finalStatus = p.finalStatus && false
-						p.Log(fmt.Sprintf("Failing Tool : %s, The tool has no associated data in the loop variable.",
-							currentFlow.Name))
-						return
-					}
-				}else{
-					p.addError(fmt.Errorf("Loop Variable is not defined: %v",currentFlow.LoopVar))
-				}
+				results := make([]models.FlowConfig,len(bindings))
+				startTime := time.Now()
+				p.notify(&currentFlow,models.NOTIFY_ON_START,config,config)
+				stepTruth := runIterations(p.ctx,currentFlow.Parallelism,len(bindings), func(idx int) bool {
+					var status bool
+					results[idx] , status = p.runToolIteration(currentFlow,toolKey,config,idx,bindings[idx])
+					return status
+				})
+				p.saveLoopState(&currentFlow,toolKey,config,results,stepTruth,time.Since(startTime))
 			}else {
 				// The current tool is not loop
 				executor := ToolExecutor{}
@@ -558,7 +833,8 @@ func (p *DagExecutor) execute(config models.FlowConfig,vertex *dag.Vertex,wg *sy
 					Tool:currentFlow.ToTool(),
 				}
 				toolInstance.Prepare()
-
+				// The conditions have already been evaluated for the whole step
+				toolInstance.Conditions = nil
 				generalConfig := p.prepareConfig(p.parentPipeline,config)
 				// RunScript the given tool
 				volumes , err := p.getAttachableVolumes(&currentFlow)
@@ -567,8 +843,11 @@ func (p *DagExecutor) execute(config models.FlowConfig,vertex *dag.Vertex,wg *sy
 					return
 				}
 				executor.SetAttachableVolumes(volumes)
+				executor.SetResourceBudget(p.budget)
+				executor.SetStepCache(p.cache)
+				executor.SetImagePuller(p.images)
 				executor.SetExplain(p.explain)
-				toolInstanceFlowConfig , err := executor.Run(toolInstance,generalConfig)
+				toolInstanceFlowConfig , err := executor.RunContext(p.ctx,toolInstance,generalConfig)
 				if err != nil {
 
 					executor.Log(fmt.Sprintf("Received Error : %s",err.Error()))
@@ -581,9 +860,6 @@ func (p *DagExecutor) execute(config models.FlowConfig,vertex *dag.Vertex,wg *sy
 						return
 					}
 				}
-				if status, ok := toolInstanceFlowConfig["status"]; ok {
-					p.finalStatus = p.finalStatus && status.(bool)
-				}
 			}
 
 		}else{
@@ -598,79 +874,73 @@ func (p *DagExecutor) execute(config models.FlowConfig,vertex *dag.Vertex,wg *sy
 				nestedPipelineConfig.Fill(config)
 				nestedPipelineConfig.Fill(pipelineConfig)
 				nestedPipelineExecutor.Setup(nestedPipelineConfig)
+				nestedPipelineExecutor.SetResourceBudget(p.budget)
+				nestedPipelineExecutor.SetImagePuller(p.images)
 				nestedPipelineExecutor.SetBasePath(toolKey)
-				err := nestedPipelineExecutor.Run(&currentFlow,nestedPipelineConfig)
+				err := nestedPipelineExecutor.RunContext(p.ctx,&currentFlow,nestedPipelineConfig)
 				if err != nil {
 
 					nestedPipelineExecutor.Log(err.Error())
 				}
 				pipeConfig := nestedPipelineExecutor.GetPipelineOutput(&toolKey)
 				pipeConfig["status"] = nestedPipelineExecutor.GetFinalStatus()
+				pipeConfig[models.STEP_USAGE_KEY] = nestedPipelineExecutor.GetUsage().ToMap()
 				if nestedPipelineExecutor.GetFinalStatus() {
 					pipeConfig["exitCode"] = 0
 				}else{
 					pipeConfig["exitCode"] = 1
 				}
+				if p.ctx.Err() != nil {
+					pipeConfig[models.STEP_STATE_KEY] = models.STEP_STATE_CANCELLED
+				}else if !nestedPipelineExecutor.GetFinalStatus() && nestedPipelineExecutor.TimedOut() {
+					pipeConfig[models.STEP_STATE_KEY] = models.STEP_STATE_TIMEOUT
+				}
 				err = p.contextManager.SaveState(toolKey,pipeConfig.GetAsMap())
 			}else{
 				// It is a nested pipeline and a loop
-				if len(currentFlow.LoopVar) == 0 {
-					p.Log(fmt.Sprintf("%s is defined as loop but no loop variable has been defined.",
-					currentFlow.Name))
+				bindings , err := models.Scatter(config,currentFlow.GetLoopVars(),currentFlow.ScatterMode)
+				if err != nil {
+					p.Log(fmt.Sprintf("%s is defined as loop but %s",currentFlow.Name,err.Error()))
+					p.addError(err)
 					p.reportFailure(toolKey,config)
 					return
 				}
-				if loop_elements , ok := config[currentFlow.LoopVar]; ok {
-					if elements, islist := loop_elements.([]interface{}); islist{
-						for idx , el := range elements{
-							nestedPipelineExecutor := DagExecutor{}
-							nestedPipelineExecutor.SetContainerConfig(p.containerConfig)
-							nestedPipelineConfig := models.FlowConfig{}
-							pipelineConfig := p.prepareConfig(&currentFlow,config)
-							nestedPipelineConfig.Fill(config)
-							nestedPipelineConfig.Fill(pipelineConfig)
-							nestedPipelineExecutor.Setup(nestedPipelineConfig)
-							nestedPipelineExecutor.SetBasePath(toolKey)
-							nestedPipelineConfig[fmt.Sprintf("%s_item",currentFlow.LoopVar)] = el
-							nestedPipelineConfig[fmt.Sprintf("loop_index")] = idx
-							inlineScripts := currentFlow.GetInLoopScripts()
-							if len(inlineScripts) > 0{
-								p.runInloopScripts(inlineScripts,nestedPipelineConfig)
-							}
-							err := nestedPipelineExecutor.Run(&currentFlow,nestedPipelineConfig)
-							if err != nil {
-								nestedPipelineExecutor.Log(err.Error())
-							}
-							pipeConfig := nestedPipelineExecutor.GetPipelineOutput(nil)
-							pipelineKeyInAloop := nestedPipelineExecutor.GetPipelineKey()
-							err = p.contextManager.SaveState(pipelineKeyInAloop,pipeConfig.GetAsMap())
-						}
-						config["status"] = true
-						config["exitCode"] = 0
-						p.finalStatus = p.finalStatus && true
-						err = p.contextManager.SaveState(toolKey,config.GetAsMap())
-						if err != nil {
-							fmt.Println(fmt.Sprintf("Received Error: %s",err.Error()))
-							return
-						}
-					}
-				}
+				results := make([]models.FlowConfig,len(bindings))
+				startTime := time.Now()
+				p.notify(&currentFlow,models.NOTIFY_ON_START,config,config)
+				stepTruth := runIterations(p.ctx,currentFlow.Parallelism,len(bindings), func(idx int) bool {
+					var status bool
+					results[idx] , status = p.runPipelineIteration(currentFlow,toolKey,config,idx,bindings[idx])
+					return status
+				})
+				p.saveLoopState(&currentFlow,toolKey,config,results,stepTruth,time.Since(startTime))
 
 			}
 
 		}
-	case SHOULD_QUEUE:
-		fallthrough
+	case ALREADY_RUN:
+		p.Log(fmt.Sprintf("Flow: %s has already run successfully, skipping....",currentFlow.Name))
+		return
+	case SHOULD_SKIP:
+		p.Log(fmt.Sprintf("Flow: %s has been skipped, it depends on a skipped step.",currentFlow.Name))
+		p.reportSkipped(toolKey,config)
+		return
 	case DONT_RUN:
+		p.Log(fmt.Sprintf("Flow: %s is blocked by a failed dependency, it will not run.",currentFlow.Name))
+		p.reportBlocked(toolKey,config)
+		return
+	case SHOULD_QUEUE:
 		fallthrough
 	default:
-		p.finalStatus = false
-		p.Log(fmt.Sprintf("Flow: %s has already run before, deferring....",currentFlow.Name))
+		p.Log(fmt.Sprintf("Flow: %s is not ready, some of its dependencies have not finished yet.",currentFlow.Name))
+		p.reportBlocked(toolKey,config)
 		return
 	}
 }
 
 func (p *DagExecutor) addError(err error) {
+	p.errorsMutex.Lock()
+	defer p.errorsMutex.Unlock()
 	p.errors = append(p.errors,err)
 }
 
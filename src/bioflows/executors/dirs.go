@@ -0,0 +1,98 @@
+package executors
+
+import (
+	config2 "bioflows/config"
+	"bioflows/expr"
+	"bioflows/models"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+	GetStepDir returns the directory of a step under the given root. The step_dir_layout of the workflow configuration
+	is a template of that directory rendered with {{pipeline}}, {{step}} and the workflow configuration,
+	the directory is named <pipeline>_<step> when it is not set.
+ */
+func GetStepDir(root string , config models.FlowConfig , pipelineId string , stepId string) string {
+	stepDir := strings.Join([]string{pipelineId,stepId},"_")
+	if layout , ok := config[config2.WF_STEP_DIR_LAYOUT]; ok && len(fmt.Sprintf("%v",layout)) > 0 {
+		exprManager := &expr.ExprManager{}
+		stepDir = exprManager.Render(fmt.Sprintf("%v",layout),map[string]interface{}{
+			"pipeline": pipelineId,
+			"step": stepId,
+		},config)
+	}
+	return filepath.Join(root,stepDir)
+}
+
+// GetScratchRoot returns where scratch directories of steps are created, defaults to the temporary directory of the system
+func GetScratchRoot(config models.FlowConfig) string {
+	if root , ok := config[config2.WF_SCRATCH_ROOT]; ok && len(fmt.Sprintf("%v",root)) > 0 {
+		return fmt.Sprintf("%v",root)
+	}
+	return os.TempDir()
+}
+
+// GetPublishDir returns where the outputs of the workflow are published, defaults to the results directory inside its output directory
+func GetPublishDir(config models.FlowConfig) string {
+	if publishDir , ok := config[config2.WF_PUBLISH_DIR]; ok && len(fmt.Sprintf("%v",publishDir)) > 0 {
+		return fmt.Sprintf("%v",publishDir)
+	}
+	return filepath.Join(fmt.Sprintf("%v",config[config2.WF_INSTANCE_OUTDIR]),"results")
+}
+
+// publishPath places the given file or directory at the target path, replacing what was published there before
+func publishPath(source string , target string , mode string) error {
+	source , err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+	target , err = filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	if source == target {
+		return nil
+	}
+	info , err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(target),config2.FILE_MODE_WRITABLE_PERM)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(target)
+	if err != nil {
+		return err
+	}
+	if mode == models.PUBLISH_SYMLINK {
+		return os.Symlink(source,target)
+	}
+	if !info.IsDir() {
+		if mode == models.PUBLISH_HARDLINK {
+			return os.Link(source,target)
+		}
+		return copyFile(source,target,info.Mode())
+	}
+	// Directories can not be hard linked, so their files are linked one by one
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative , err := filepath.Rel(source,path)
+		if err != nil {
+			return err
+		}
+		current := filepath.Join(target,relative)
+		if info.IsDir() {
+			return os.MkdirAll(current,info.Mode())
+		}
+		if mode == models.PUBLISH_HARDLINK {
+			return os.Link(path,current)
+		}
+		return copyFile(path,current,info.Mode())
+	})
+}
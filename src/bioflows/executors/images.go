@@ -0,0 +1,157 @@
+package executors
+
+import (
+	config2 "bioflows/config"
+	dockcontainer "bioflows/container"
+	"bioflows/models"
+	"bioflows/models/pipelines"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// getImageURL returns where the given image is pulled from, docker hub is used unless the container configuration has its own registry
+func getImageURL(image string , containerConfig *models.ContainerConfig) string {
+	if containerConfig == nil {
+		return fmt.Sprintf("%s/%s",dockcontainer.DOCKER_REPOSITORY,image)
+	}
+	return fmt.Sprintf("%s/%s",containerConfig.URL,image)
+}
+
+// getPullPolicy returns the pull policy of a step, the pull_policy of BioFlows configuration is used when the step has none
+func getPullPolicy(policy string , config models.FlowConfig) (string,error) {
+	if len(policy) == 0 {
+		if value , ok := config[config2.WF_PULL_POLICY]; ok {
+			policy = fmt.Sprintf("%v",value)
+		}
+	}
+	return models.GetPullPolicy(policy)
+}
+
+type imagePull struct {
+	done chan struct{}
+	err error
+}
+
+/*
+	ImagePuller makes the container images of a run available according to their pull policies.
+	An image is pulled at most once during the run however many steps or loop iterations use it,
+	steps asking for an image which is being pulled wait for that pull to finish. Failed pulls are attempted again.
+ */
+type ImagePuller struct {
+	mutex sync.Mutex
+	manager dockcontainer.DockerManager
+	images map[string]*imagePull
+}
+
+func NewImagePuller() *ImagePuller {
+	return &ImagePuller{
+		images: make(map[string]*imagePull),
+	}
+}
+
+// Pull makes the given image available locally according to the pull policy, log receives the progress of the pull
+func (i *ImagePuller) Pull(image string , containerConfig *models.ContainerConfig , policy string , log func(...interface{})) error {
+	imageURL := getImageURL(image,containerConfig)
+	i.mutex.Lock()
+	pull , ok := i.images[imageURL]
+	if !ok {
+		pull = &imagePull{done: make(chan struct{})}
+		i.images[imageURL] = pull
+	}
+	i.mutex.Unlock()
+	if ok {
+		<- pull.done
+		return pull.err
+	}
+	pull.err = i.pull(image,imageURL,containerConfig,policy,log)
+	if pull.err != nil {
+		i.mutex.Lock()
+		delete(i.images,imageURL)
+		i.mutex.Unlock()
+	}
+	close(pull.done)
+	return pull.err
+}
+
+func (i *ImagePuller) pull(image string , imageURL string , containerConfig *models.ContainerConfig , policy string , log func(...interface{})) error {
+	if policy != models.PULL_ALWAYS {
+		if _ , err := i.manager.GetImageId(image); err == nil {
+			return nil
+		}
+		if policy == models.PULL_NEVER {
+			return fmt.Errorf("Image (%s) is not present locally and its pull policy is (%s)",image,models.PULL_NEVER)
+		}
+	}
+	log(fmt.Sprintf("Pulling Image (%s)....",imageURL))
+	output , err := i.manager.PullImage(imageURL,containerConfig)
+	if err != nil {
+		return fmt.Errorf("Unable to pull Image (%s): %s",imageURL,err.Error())
+	}
+	log(output)
+	return nil
+}
+
+type imageRequest struct {
+	image string
+	containerConfig *models.ContainerConfig
+	policy string
+}
+
+// collectImages walks the given steps and their nested pipelines, every step gets the container configuration and pull policy of its pipeline unless it has its own
+func collectImages(steps []pipelines.BioPipeline , containerConfig *models.ContainerConfig , policy string , requests []imageRequest) []imageRequest {
+	for _ , step := range steps {
+		stepContainerConfig , stepPolicy := containerConfig , policy
+		if step.ContainerConfig != nil {
+			stepContainerConfig = step.ContainerConfig
+		}
+		if len(step.PullPolicy) > 0 {
+			stepPolicy = step.PullPolicy
+		}
+		if len(step.ImageId) > 1 {
+			requests = append(requests,imageRequest{image: step.ImageId,containerConfig: stepContainerConfig,policy: stepPolicy})
+		}
+		requests = collectImages(step.Steps,stepContainerConfig,stepPolicy,requests)
+	}
+	return requests
+}
+
+/*
+	prefetchImages pulls the images of all the steps of the given pipeline concurrently before any of them runs,
+	so a failing pull is reported before anything executes. Steps defined by a URL are only known once they run,
+	their images are pulled then.
+ */
+func (p *DagExecutor) prefetchImages(b *pipelines.BioPipeline , config models.FlowConfig) error {
+	if p.images == nil {
+		p.images = NewImagePuller()
+	}
+	requests := collectImages(b.Steps,p.containerConfig,b.PullPolicy,nil)
+	var wait sync.WaitGroup
+	errs := make([]error,len(requests))
+	for idx , request := range requests {
+		policy , err := getPullPolicy(request.policy,config)
+		if err != nil {
+			errs[idx] = err
+			continue
+		}
+		wait.Add(1)
+		go func(idx int , request imageRequest , policy string){
+			defer wait.Done()
+			errs[idx] = p.images.Pull(request.image,request.containerConfig,policy,p.Log)
+		}(idx,request,policy)
+	}
+	wait.Wait()
+	messages := make([]string,0)
+	seen := make(map[string]bool)
+	for _ , err := range errs {
+		if err != nil && !seen[err.Error()] {
+			seen[err.Error()] = true
+			messages = append(messages,err.Error())
+		}
+	}
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages,"\n"))
+	}
+	return nil
+}
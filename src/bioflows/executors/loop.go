@@ -0,0 +1,114 @@
+package executors
+
+import (
+	"bioflows/models"
+	"bioflows/models/pipelines"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+	runIterations runs the given iteration for every element of a loop, at most parallelism iterations
+	run at the same time (sequentially when it is less than 2). No new iteration is started once the context is done.
+	It returns true only if every iteration has run and succeeded.
+ */
+func runIterations(ctx context.Context , parallelism int , count int , iteration func(idx int) bool) bool {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	slots := make(chan struct{},parallelism)
+	var waitGroup sync.WaitGroup
+	var mutex sync.Mutex
+	result := true
+	for idx := 0 ; idx < count ; idx++ {
+		select {
+		case slots <- struct{}{}:
+		case <- ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		waitGroup.Add(1)
+		go func(idx int){
+			defer waitGroup.Done()
+			defer func(){
+				<- slots
+			}()
+			status := iteration(idx)
+			mutex.Lock()
+			result = result && status
+			mutex.Unlock()
+		}(idx)
+	}
+	waitGroup.Wait()
+	if ctx.Err() != nil {
+		return false
+	}
+	return result
+}
+
+/*
+	gatherOutputs collects the named outputs of every iteration of a loop into arrays, in the order of the iterations,
+	so downstream steps could consume all of them at once. Iterations which did not run leave a nil element.
+ */
+func gatherOutputs(outputs []models.Parameter , results []models.FlowConfig) models.FlowConfig {
+	gathered := models.FlowConfig{}
+	for _ , param := range outputs {
+		values := make([]interface{},len(results))
+		for idx , result := range results {
+			if result == nil {
+				continue
+			}
+			values[idx] = result[param.Name]
+		}
+		gathered[param.Name] = values
+	}
+	return gathered
+}
+
+// gatherUsage sums up the cpu times of all iterations of a loop and keeps their highest peak, the wall time covers the whole loop
+func gatherUsage(wallTime time.Duration , results []models.FlowConfig) models.ResourceUsage {
+	usage := models.ResourceUsage{WallTime: wallTime}
+	for _ , result := range results {
+		if iterationUsage , ok := models.GetUsageFromState(result); ok {
+			usage.Combine(iterationUsage)
+		}
+	}
+	return usage
+}
+
+/*
+	saveLoopState records the state of a loop step once all of its iterations have finished, with the outputs and the usage
+	gathered from them. The loop has succeeded only if every iteration has, it is recorded as timed out or cancelled
+	when the pipeline has been stopped while it was running.
+ */
+func (p *DagExecutor) saveLoopState(step *pipelines.BioPipeline , toolKey string , config models.FlowConfig , results []models.FlowConfig , stepTruth bool , wallTime time.Duration) {
+	loopState := models.FlowConfig{}
+	loopState.Fill(config)
+	loopState.Fill(gatherOutputs(step.Outputs,results))
+	loopState[models.STEP_USAGE_KEY] = gatherUsage(wallTime,results).ToMap()
+	loopState["status"] = stepTruth && p.ctx.Err() == nil
+	switch {
+	case p.ctx.Err() == context.DeadlineExceeded:
+		loopState[models.STEP_STATE_KEY] = models.STEP_STATE_TIMEOUT
+	case p.ctx.Err() != nil:
+		loopState[models.STEP_STATE_KEY] = models.STEP_STATE_CANCELLED
+	case stepTruth:
+		loopState[models.STEP_STATE_KEY] = models.STEP_STATE_SUCCEEDED
+	default:
+		loopState[models.STEP_STATE_KEY] = models.STEP_STATE_FAILED
+	}
+	loopState["exitCode"] = 0
+	if loopState[models.STEP_STATE_KEY] == models.STEP_STATE_TIMEOUT {
+		loopState["exitCode"] = models.EXIT_CODE_TIMEOUT
+	}else if loopState["status"] != true {
+		loopState["exitCode"] = 1
+	}
+	p.notify(step,getNotificationEvent(loopState),loopState,config)
+	err := p.contextManager.SaveState(toolKey,loopState.GetAsMap())
+	if err != nil {
+		fmt.Println(fmt.Sprintf("Received Error: %s",err.Error()))
+	}
+}
@@ -0,0 +1,32 @@
+package executors
+
+import (
+	"bioflows/models"
+	"bioflows/models/pipelines"
+	"bioflows/notifications"
+)
+
+// getNotificationEvent tells which event the final configuration of a step stands for, skipped and impeded steps raise none
+func getNotificationEvent(stepConfig models.FlowConfig) string {
+	if stepConfig == nil {
+		return models.NOTIFY_ON_FAILURE
+	}
+	if stepConfig["impede"] == true || stepConfig[models.STEP_STATE_KEY] == models.STEP_STATE_SKIPPED {
+		return ""
+	}
+	if status , ok := stepConfig["status"].(bool); ok && status {
+		return models.NOTIFY_ON_SUCCESS
+	}
+	return models.NOTIFY_ON_FAILURE
+}
+
+// notify sends the notification of the given step or pipeline for the given event
+func (p *DagExecutor) notify(step *pipelines.BioPipeline , event string , stepConfig models.FlowConfig , systemConfig models.FlowConfig) {
+	if len(event) == 0 {
+		return
+	}
+	err := notifications.Send(step.Notification,event,step.Name,stepConfig,systemConfig)
+	if err != nil {
+		p.Log(err.Error())
+	}
+}
@@ -0,0 +1,90 @@
+package executors
+
+import (
+	"bioflows/config"
+	"bioflows/helpers"
+	"bioflows/models"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// stepOutput holds where the stdout and stderr of a running command are streamed to
+type stepOutput struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	closers []io.Closer
+}
+
+// Close flushes and closes all the destinations of the output
+func (o *stepOutput) Close() error {
+	if o == nil {
+		return nil
+	}
+	var err error
+	for _ , closer := range o.closers {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	o.closers = nil
+	return err
+}
+
+/*
+	openOutput prepares the destinations of the stdout and stderr of the tool, they are streamed into the per step files
+	with the given names while the command runs. With the stdout directive, stdout is piped into the file of the named output
+	(as rendered in outputs and resolved against the given working directory) instead, and with the tee directive both are also written to the console prefixed by the tool name.
+	Shadow tools do not keep their output.
+ */
+func (e *ToolExecutor) openOutput(outputs models.FlowConfig , workDir string , stdoutName string , stderrName string) (*stepOutput,error) {
+	output := &stepOutput{Stdout: ioutil.Discard,Stderr: ioutil.Discard}
+	if !e.ToolInstance.Shadow || len(e.ToolInstance.Stdout) > 0 {
+		var stdoutFile string
+		flags := os.O_WRONLY|os.O_CREATE|os.O_APPEND
+		if len(e.ToolInstance.Stdout) > 0 {
+			if !e.ToolInstance.DeclaresOutput(e.ToolInstance.Stdout) {
+				return output , fmt.Errorf("Tool (%s) pipes its stdout into output (%s) which is not declared",e.ToolInstance.Name,e.ToolInstance.Stdout)
+			}
+			stdoutFile = resolvePath(workDir,fmt.Sprintf("%v",outputs[e.ToolInstance.Stdout]))
+			flags = os.O_WRONLY|os.O_CREATE|os.O_TRUNC
+		}else{
+			location , err := e.CreateOutputFile(stdoutName,"out")
+			if err != nil {
+				return output , err
+			}
+			stdoutFile = location
+		}
+		file , err := os.OpenFile(stdoutFile,flags,config.FILE_MODE_WRITABLE_PERM)
+		if err != nil {
+			return output , err
+		}
+		output.Stdout = file
+		output.closers = append(output.closers,file)
+	}
+	if !e.ToolInstance.Shadow {
+		location , err := e.CreateOutputFile(stderrName,"err")
+		if err != nil {
+			output.Close()
+			return output , err
+		}
+		file , err := os.OpenFile(location,os.O_WRONLY|os.O_CREATE|os.O_APPEND,config.FILE_MODE_WRITABLE_PERM)
+		if err != nil {
+			output.Close()
+			return output , err
+		}
+		output.Stderr = file
+		output.closers = append(output.closers,file)
+	}
+	if e.ToolInstance.Tee {
+		prefix := fmt.Sprintf("[%s] ",e.ToolInstance.Name)
+		stdoutConsole := helpers.NewPrefixWriter(prefix,os.Stdout)
+		stderrConsole := helpers.NewPrefixWriter(prefix,os.Stderr)
+		output.Stdout = io.MultiWriter(output.Stdout,stdoutConsole)
+		output.Stderr = io.MultiWriter(output.Stderr,stderrConsole)
+		// The console writers are flushed before the files are closed
+		output.closers = append([]io.Closer{stdoutConsole,stderrConsole},output.closers...)
+	}
+	return output , nil
+}
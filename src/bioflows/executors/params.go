@@ -0,0 +1,51 @@
+package executors
+
+import (
+	"bioflows/expr"
+	"bioflows/models"
+)
+
+/*
+	ResolveParameters evaluates every parameter against the given configuration, the declared value (if any) is rendered,
+	otherwise the value already in the configuration is used (e.g. passed on the command line). The value is then coerced to
+	the type of the parameter and validated, and written back into the configuration. All the problems are reported together.
+	Additional contexts are used to render the declared values, checkPaths makes sure that file and dir parameters exist.
+ */
+func ResolveParameters(params []models.Parameter , config models.FlowConfig , checkPaths bool , contexts ...interface{}) error {
+	exprManager := &expr.ExprManager{}
+	renderContexts := append([]interface{}{config},contexts...)
+	errs := make(models.ParameterErrors,0)
+	for _ , param := range params {
+		if len(param.Name) == 0 {
+			continue
+		}
+		var value interface{}
+		if param.Value != nil {
+			value = param.Value
+			if text , ok := param.Value.(string); ok {
+				value = exprManager.Render(text,renderContexts...)
+			}
+		}else{
+			value = config[param.Name]
+		}
+		resolved , err := param.Resolve(value)
+		if err == nil && checkPaths {
+			err = param.CheckPath(resolved)
+		}
+		if err != nil {
+			errs = append(errs,err)
+			continue
+		}
+		if resolved == nil {
+			if _ , ok := config[param.Name]; !ok || param.Value != nil {
+				config[param.Name] = ""
+			}
+			continue
+		}
+		config[param.Name] = resolved
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -21,7 +21,8 @@ const (
 	SHOULD_RUN = iota
 	DONT_RUN
 	SHOULD_QUEUE
-
+	ALREADY_RUN
+	SHOULD_SKIP
 )
 
 type PipelineExecutor struct {
@@ -0,0 +1,156 @@
+package executors
+
+import (
+	config2 "bioflows/config"
+	"bioflows/helpers/profiling"
+	"bioflows/models"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+/*
+	ResourceBudget keeps track of the CPU cores and memory (in MB) of the current node which are
+	used by the running steps. A step is only allowed to start once its declared caps fit into what is left.
+	A zero value for any of the limits means that this limit is not enforced.
+ */
+type ResourceBudget struct {
+	mutex sync.Mutex
+	cpu int
+	memory int
+	maxParallel int
+	usedCPU int
+	usedMemory int
+	running int
+	// closed and renewed every time resources are released to wake up all waiting steps
+	released chan struct{}
+}
+
+func NewResourceBudget(cpu , memory , maxParallel int) *ResourceBudget {
+	return &ResourceBudget{
+		cpu: cpu,
+		memory: memory,
+		maxParallel: maxParallel,
+		released: make(chan struct{}),
+	}
+}
+
+// NewResourceBudgetFromConfig reads the resources section of BioFlows configuration
+// and falls back to the profile of the current node for the missing limits
+func NewResourceBudgetFromConfig(config models.FlowConfig) *ResourceBudget {
+	var cpu , memory , maxParallel int
+	if section , ok := config[config2.WF_RESOURCES]; ok {
+		switch resources := section.(type) {
+		case map[string]interface{}:
+			cpu = toInt(resources["cpu"])
+			memory = toInt(resources["memory"])
+			maxParallel = toInt(resources[config2.WF_MAX_PARALLEL])
+		case map[interface{}]interface{}:
+			cpu = toInt(resources["cpu"])
+			memory = toInt(resources["memory"])
+			maxParallel = toInt(resources[config2.WF_MAX_PARALLEL])
+		}
+	}
+	if value , ok := config[config2.WF_MAX_PARALLEL]; ok && toInt(value) > 0 {
+		maxParallel = toInt(value)
+	}
+	if cpu <= 0 {
+		cpu = profiling.GetCPU()
+	}
+	if memory <= 0 {
+		memory = profiling.GetTotalMemory()
+	}
+	return NewResourceBudget(cpu,memory,maxParallel)
+}
+
+// requirements returns the cpu and memory declared by the given caps, clamped to the node capacity
+func (r *ResourceBudget) requirements(caps *models.Capabilities) (int,int) {
+	// a step without caps is assumed to occupy a single core
+	cpu , memory := 1 , 0
+	if caps != nil {
+		if caps.CPU > 0 {
+			cpu = caps.CPU
+		}
+		if caps.Memory > 0 {
+			memory = caps.Memory
+		}
+	}
+	// A step which asks for more than the whole node would never start, so it gets the whole node instead
+	if r.cpu > 0 && cpu > r.cpu {
+		cpu = r.cpu
+	}
+	if r.memory > 0 && memory > r.memory {
+		memory = r.memory
+	}
+	return cpu , memory
+}
+
+func (r *ResourceBudget) fits(cpu , memory int) bool {
+	if r.maxParallel > 0 && r.running >= r.maxParallel {
+		return false
+	}
+	if r.cpu > 0 && r.usedCPU + cpu > r.cpu {
+		return false
+	}
+	if r.memory > 0 && r.usedMemory + memory > r.memory {
+		return false
+	}
+	return true
+}
+
+// Acquire blocks until the given caps fit into the budget or the context is done
+func (r *ResourceBudget) Acquire(ctx context.Context,caps *models.Capabilities) error {
+	cpu , memory := r.requirements(caps)
+	for {
+		r.mutex.Lock()
+		if r.fits(cpu,memory) {
+			r.usedCPU += cpu
+			r.usedMemory += memory
+			r.running++
+			r.mutex.Unlock()
+			return nil
+		}
+		released := r.released
+		r.mutex.Unlock()
+		select {
+		case <- released:
+		case <- ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release gives back the resources taken by a previous call to Acquire with the same caps
+func (r *ResourceBudget) Release(caps *models.Capabilities) {
+	cpu , memory := r.requirements(caps)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.usedCPU -= cpu
+	r.usedMemory -= memory
+	r.running--
+	close(r.released)
+	r.released = make(chan struct{})
+}
+
+func (r *ResourceBudget) String() string {
+	return fmt.Sprintf("CPU: %d , Memory: %d MB , Max Parallel: %d",r.cpu,r.memory,r.maxParallel)
+}
+
+func toInt(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		i , err := strconv.Atoi(v)
+		if err != nil {
+			return 0
+		}
+		return i
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package executors
+
+import (
+	"bioflows/models"
+	"context"
+	"testing"
+	"time"
+)
+
+// acquireAsync reports on the returned channel once the caps have been acquired
+func acquireAsync(budget *ResourceBudget , caps *models.Capabilities) chan error {
+	acquired := make(chan error,1)
+	go func(){
+		acquired <- budget.Acquire(context.Background(),caps)
+	}()
+	return acquired
+}
+
+func expectBlocked(t *testing.T , acquired chan error) {
+	select {
+	case <- acquired:
+		t.Fatal("the step has started although it does not fit into the budget")
+	case <- time.After(50 * time.Millisecond):
+	}
+}
+
+func expectAcquired(t *testing.T , acquired chan error) {
+	select {
+	case err := <- acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <- time.After(5 * time.Second):
+		t.Fatal("the step has not started after the resources have been released")
+	}
+}
+
+func TestResourceBudgetCPU(t *testing.T) {
+	budget := NewResourceBudget(4,0,0)
+	large := &models.Capabilities{CPU: 3}
+	if err := budget.Acquire(context.Background(),large); err != nil {
+		t.Fatal(err)
+	}
+	// a step without caps occupies a single core
+	if err := budget.Acquire(context.Background(),nil); err != nil {
+		t.Fatal(err)
+	}
+	acquired := acquireAsync(budget,&models.Capabilities{CPU: 2})
+	expectBlocked(t,acquired)
+	budget.Release(nil)
+	expectBlocked(t,acquired)
+	budget.Release(large)
+	expectAcquired(t,acquired)
+}
+
+func TestResourceBudgetMemory(t *testing.T) {
+	budget := NewResourceBudget(0,4096,0)
+	first := &models.Capabilities{Memory: 3072}
+	if err := budget.Acquire(context.Background(),first); err != nil {
+		t.Fatal(err)
+	}
+	acquired := acquireAsync(budget,&models.Capabilities{Memory: 2048})
+	expectBlocked(t,acquired)
+	budget.Release(first)
+	expectAcquired(t,acquired)
+}
+
+func TestResourceBudgetMaxParallel(t *testing.T) {
+	budget := NewResourceBudget(0,0,1)
+	if err := budget.Acquire(context.Background(),nil); err != nil {
+		t.Fatal(err)
+	}
+	acquired := acquireAsync(budget,nil)
+	expectBlocked(t,acquired)
+	budget.Release(nil)
+	expectAcquired(t,acquired)
+}
+
+func TestResourceBudgetClampsToNode(t *testing.T) {
+	budget := NewResourceBudget(2,1024,0)
+	ctx , cancel := context.WithTimeout(context.Background(),time.Second)
+	defer cancel()
+	huge := &models.Capabilities{CPU: 64,Memory: 65536}
+	if err := budget.Acquire(ctx,huge); err != nil {
+		t.Fatalf("a step asking for more than the node should get the whole node: %s",err.Error())
+	}
+	budget.Release(huge)
+	if budget.usedCPU != 0 || budget.usedMemory != 0 || budget.running != 0 {
+		t.Errorf("the budget has not been fully released: %s",budget.String())
+	}
+}
+
+func TestResourceBudgetContextDone(t *testing.T) {
+	budget := NewResourceBudget(1,0,0)
+	if err := budget.Acquire(context.Background(),nil); err != nil {
+		t.Fatal(err)
+	}
+	ctx , cancel := context.WithCancel(context.Background())
+	acquired := make(chan error,1)
+	go func(){
+		acquired <- budget.Acquire(ctx,nil)
+	}()
+	expectBlocked(t,acquired)
+	cancel()
+	select {
+	case err := <- acquired:
+		if err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v",err)
+		}
+	case <- time.After(5 * time.Second):
+		t.Fatal("Acquire has not returned after its context has been cancelled")
+	}
+}
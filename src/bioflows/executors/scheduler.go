@@ -2,77 +2,46 @@ package executors
 
 import (
 	"bioflows/models/pipelines"
-	"bioflows/resolver"
 	"github.com/goombaio/dag"
 )
 
 type DagScheduler struct {
-	rankedNodes map[string] int
-	nodeNames map[string] *dag.Vertex
 	pipeline *pipelines.BioPipeline
-}
-func (d *DagScheduler) getValue(node *dag.Vertex) int {
-	if val , ok := d.rankedNodes[resolver.ResolveToolKey(node.ID,d.pipeline.ID)]; ok {
-		return val
-	}
-	return 0
-}
-
-func (d *DagScheduler) getMaxRank() int {
-	max := -1
-	for _ , val := range d.rankedNodes {
-		if val > max {
-			max = val
-		}
-	}
-	return max
+	// number of parents every vertex is still waiting for
+	remaining map[string] int
 }
 
-func (d *DagScheduler) prepareNodes() ([][]*dag.Vertex,error) {
-	sortedNodes := make([][]*dag.Vertex,d.getMaxRank()+1)
-	for key , val := range d.rankedNodes {
-		if sortedNodes[val] == nil {
-			sortedNodes[val] = make([]*dag.Vertex,1)
-		}
-		sortedNodes[val] = append(sortedNodes[val],d.nodeNames[key])
-	}
-	return sortedNodes , nil
-}
-func (d *DagScheduler) Rank(parentPipeline *pipelines.BioPipeline , pb *dag.DAG) ([][]*dag.Vertex,error){
+/*
+	Prepare initializes the ready queue of the scheduler, each vertex is released
+	the moment all of its parents have completed rather than waiting for its whole rank.
+ */
+func (d *DagScheduler) Prepare(parentPipeline *pipelines.BioPipeline , pb *dag.DAG) []*dag.Vertex {
 	d.pipeline = parentPipeline
-	d.rankedNodes = make(map[string]int)
-	d.nodeNames = make(map[string]*dag.Vertex)
-	parents := pb.SourceVertices()
-	rank := 0
-	for _ , parent := range parents {
-		d.rankVertex(rank, parent)
-	}
-	preparedList , err := d.prepareNodes()
-	if err != nil {
-		return nil , err
-	}
-	rankedList := make([][]*dag.Vertex,0)
-	total := len(preparedList)
-	for idx := 0 ; idx < total;idx++ {
-		sublist := preparedList[idx]
-		if sublist == nil || len(sublist) == 0{
+	d.remaining = make(map[string]int)
+	ready := make([]*dag.Vertex,0)
+	for _ , parent := range pb.SourceVertices() {
+		if _ , ok := d.remaining[parent.ID]; ok {
 			continue
 		}
-		rankedList = append(rankedList,sublist)
+		d.remaining[parent.ID] = 0
+		ready = append(ready,parent)
 	}
-	return rankedList , nil
-
+	return ready
 }
-func (d *DagScheduler) rankVertex(pRank int, node *dag.Vertex) {
-	currentNodeName := resolver.ResolveToolKey(node.ID,d.pipeline.ID)
-	val := d.getValue(node)
-	nodeRank := val + pRank +  node.InDegree()
-	if _ , ok := d.rankedNodes[currentNodeName] ; !ok {
-		d.rankedNodes[currentNodeName] = nodeRank
-	}
-	d.nodeNames[resolver.ResolveToolKey(node.ID,d.pipeline.ID)] = node
+// Complete marks the given vertex as finished and returns its children which have no more pending parents
+func (d *DagScheduler) Complete(node *dag.Vertex) []*dag.Vertex {
+	ready := make([]*dag.Vertex,0)
 	for _ , child := range node.Children.Values() {
 		childNode := child.(*dag.Vertex)
-		d.rankVertex(nodeRank,childNode)
+		pending , ok := d.remaining[childNode.ID]
+		if !ok {
+			pending = childNode.InDegree()
+		}
+		pending--
+		d.remaining[childNode.ID] = pending
+		if pending == 0 {
+			ready = append(ready,childNode)
+		}
 	}
+	return ready
 }
@@ -4,19 +4,21 @@ import (
 	"bioflows/config"
 	dockcontainer "bioflows/container"
 	"bioflows/expr"
-	"bioflows/helpers"
 	"bioflows/models"
+	"bioflows/notifications"
 	"bioflows/process"
 	"bioflows/scripts"
 	"bioflows/virtualization"
+	"context"
 	"fmt"
 	"github.com/aidarkhanov/nanoid"
 	"github.com/docker/docker/api/types/container"
 	"log"
-	"net/smtp"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 )
 
 type ToolExecutor struct {
@@ -34,6 +36,12 @@ type ToolExecutor struct {
 	basePath string
 	instanceId string
 	explain bool
+	ctx context.Context
+	// stepCtx is derived from ctx and bounded by the timeout of the tool, it is renewed on every attempt
+	stepCtx context.Context
+	budget *ResourceBudget
+	cache *StepCache
+	images *ImagePuller
 }
 func (t *ToolExecutor) GetInstanceId() string{
 	return t.instanceId
@@ -52,39 +60,30 @@ func (e *ToolExecutor) SetPipelineName(name string) {
 	e.pipelineName = strings.ReplaceAll(name," ","_")
 }
 
-func (e *ToolExecutor) notify(tool *models.ToolInstance) {
-	if tool.Notification != nil {
-
-		if EmailSection , ok := e.flowConfig["email"]; !ok {
-			err := fmt.Errorf("Tool (%s) requires Email notification but BioFlows Configuration is missing The Email Section...",tool.Name)
-			e.Log(err.Error())
-		}else{
-			email := EmailSection.(map[string]interface{})
-			username := fmt.Sprintf("%v",email["username"])
-			password := fmt.Sprintf("%v",email["password"])
-			SMTPHost := fmt.Sprintf("%v",email["host"])
-			SMTPPort := email["port"].(int)
-			message := []byte(tool.Notification.Body)
-			auth := smtp.PlainAuth("",username,password,SMTPHost)
-			To := strings.Split(tool.Notification.To,",")
-			e.Log("Start Sending Email Notifications....")
-			err := smtp.SendMail(fmt.Sprintf("%s:%d",SMTPHost,SMTPPort),auth,username,To,message)
-			if err != nil {
-				e.Log(err.Error())
-			}
-			e.Log(fmt.Sprintf("Tool (%s): The Email was sent Successfully....",tool.Name))
-		}
-
+// notify sends the notification of the tool for the given event, the final configuration of the tool decides between success and failure
+func (e *ToolExecutor) notify(event string , toolConfig models.FlowConfig) {
+	if len(event) == 0 {
+		return
+	}
+	stepConfig := models.FlowConfig{}
+	stepConfig.Fill(e.flowConfig)
+	stepConfig.Fill(toolConfig)
+	err := notifications.Send(e.ToolInstance.Notification,event,e.ToolInstance.Name,stepConfig,e.flowConfig)
+	if err != nil {
+		e.Log(err.Error())
 	}
 }
 
-func (e *ToolExecutor) prepareParameters() models.FlowConfig {
+func (e *ToolExecutor) prepareParameters() (models.FlowConfig,error) {
 
 	flowConfig := make(models.FlowConfig)
 	toolConfigKey , toolDir , _ := e.GetToolOutputDir()
 	flowConfig[toolConfigKey] = toolDir
 	flowConfig["self_dir"] = toolDir
 	flowConfig["location"] = toolDir
+	if scratchDir := e.GetScratchDir(); len(scratchDir) > 0 {
+		flowConfig["scratch_dir"] = scratchDir
+	}
 	//Copy all flow configs at the workflow level into the current tool flowconfig
 	if len(e.flowConfig) > 0 {
 		for k,v := range e.flowConfig{
@@ -107,17 +106,11 @@ func (e *ToolExecutor) prepareParameters() models.FlowConfig {
 		}
 	}
 	if len(e.ToolInstance.Inputs) > 0 {
-		inputs := make(map[string]string)
-		for _ , param := range e.ToolInstance.Inputs{
-			if param.Value == nil {
-				continue
-			}
-			paramValue := e.exprManager.Render(param.GetParamValue(),flowConfig)
-			inputs[param.Name] = paramValue
-		}
-		//Append the processed input parameters into the current flowConfig
-		for k , v := range inputs {
-			flowConfig[k] = v
+		//Coerce and validate the input parameters into the current flowConfig
+		err := ResolveParameters(e.ToolInstance.Inputs,flowConfig,false)
+		if err != nil {
+			e.addImplicitVariables(&flowConfig)
+			return flowConfig , err
 		}
 	}
 	if len(e.ToolInstance.Outputs) > 0{
@@ -133,7 +126,7 @@ func (e *ToolExecutor) prepareParameters() models.FlowConfig {
 		}
 	}
 	e.addImplicitVariables(&flowConfig)
-	return flowConfig
+	return flowConfig , nil
 }
 func (e *ToolExecutor) addImplicitVariables(config *models.FlowConfig){
 	//This variable might be used by embedded scripts to impede the firing of the current tool
@@ -141,7 +134,11 @@ func (e *ToolExecutor) addImplicitVariables(config *models.FlowConfig){
 	(*config)["impede"] = false
 }
 func (e *ToolExecutor) executeBeforeScripts() (map[string]interface{},error) {
-	configuration := e.prepareParameters()
+	configuration , err := e.prepareParameters()
+	if err != nil {
+		e.Log(fmt.Sprintf("Tool (%s): %s",e.ToolInstance.Name,err.Error()))
+		return configuration , err
+	}
 	configuration["command"] = e.ToolInstance.Command.ToString()
 	beforeScripts := make([]models.Script,0)
 	for idx , script := range e.ToolInstance.Scripts {
@@ -213,12 +210,100 @@ func (e *ToolExecutor) GetToolOutputDir() (toolConfigKey string,toolDir string,e
 		err = fmt.Errorf("Unable to get the Tool/Workflow Output Directory")
 		return
 	}
-	toolOutputDir := strings.Join([]string{e.pipelineName,e.ToolInstance.ID},"_")
-	toolDir = strings.Join([]string{fmt.Sprintf("%v",workflowOutputDir),toolOutputDir},"/")
+	toolDir = GetStepDir(fmt.Sprintf("%v",workflowOutputDir),e.flowConfig,e.pipelineName,e.ToolInstance.ID)
 	preparedToolName := strings.ReplaceAll(e.ToolInstance.ID," ","_")
 	toolConfigKey = fmt.Sprintf("%s_dir",preparedToolName)
 	return
 }
+// GetScratchDir returns the scratch directory of the tool, it is empty when the tool does not ask for one
+func (e *ToolExecutor) GetScratchDir() string {
+	if !e.ToolInstance.Scratch {
+		return ""
+	}
+	return e.getOutputName(GetStepDir(GetScratchRoot(e.flowConfig),e.flowConfig,e.pipelineName,e.ToolInstance.ID))
+}
+// createScratchDir creates the given scratch directory and makes it available to the container of the tool
+func (e *ToolExecutor) createScratchDir(scratchDir string) error {
+	if len(scratchDir) == 0 {
+		return nil
+	}
+	err := os.MkdirAll(scratchDir,config.FILE_MODE_WRITABLE_PERM)
+	if err != nil {
+		return fmt.Errorf("Tool (%s) could not create its scratch directory (%s): %s",e.ToolInstance.Name,scratchDir,err.Error())
+	}
+	if e.isDockerized() {
+		e.dockerManager.AddAttachableVolume(scratchDir)
+	}
+	return nil
+}
+// cleanScratchDir removes the scratch directory once the tool has succeeded, unless it should be kept
+func (e *ToolExecutor) cleanScratchDir(scratchDir string , toolConfig models.FlowConfig) {
+	if len(scratchDir) == 0 || e.ToolInstance.KeepScratch || toolConfig["status"] != true {
+		return
+	}
+	err := os.RemoveAll(scratchDir)
+	if err != nil {
+		e.Log(fmt.Sprintf("Tool (%s) could not clean its scratch directory (%s): %s",e.ToolInstance.Name,scratchDir,err.Error()))
+	}
+}
+/*
+	publishOutputs places the outputs selected by the publish directive of the tool into the publish directory of the workflow.
+	Lists of paths (e.g. glob outputs) are published path by path, outputs which are not files or directories are skipped.
+	Relative outputs are resolved against the given working directory of the tool.
+ */
+func (e *ToolExecutor) publishOutputs(toolConfig models.FlowConfig , workDir string) error {
+	publish := e.ToolInstance.Publish
+	if publish == nil || e.ToolInstance.Shadow {
+		return nil
+	}
+	mode , err := publish.GetMode()
+	if err != nil {
+		return err
+	}
+	publishPathStr := publish.Path
+	if len(publishPathStr) == 0 {
+		publishPathStr = e.ToolInstance.ID
+	}
+	publishDir := filepath.Join(GetPublishDir(e.flowConfig),e.exprManager.Render(publishPathStr,toolConfig))
+	for _ , param := range e.ToolInstance.Outputs {
+		if !publish.Publishes(param.Name) {
+			continue
+		}
+		switch param.GetType() {
+		case models.PARAM_TYPE_STRING , models.PARAM_TYPE_INT , models.PARAM_TYPE_FLOAT , models.PARAM_TYPE_BOOL ,
+			models.PARAM_TYPE_ENUM , models.PARAM_TYPE_ARRAY:
+			continue
+		}
+		paths := make([]string,0)
+		switch value := toolConfig[param.Name].(type) {
+		case string:
+			paths = append(paths,value)
+		case []string:
+			paths = append(paths,value...)
+		case []interface{}:
+			for _ , element := range value {
+				if element != nil {
+					paths = append(paths,fmt.Sprintf("%v",element))
+				}
+			}
+		}
+		for _ , path := range paths {
+			if len(path) == 0 || strings.Contains(path,"://") {
+				continue
+			}
+			path = resolvePath(workDir,path)
+			// Untyped and optional outputs are published only when they exist
+			if _ , err := os.Stat(path); err != nil && (param.Optional || len(param.GetType()) == 0) {
+				continue
+			}
+			err = publishPath(path,filepath.Join(publishDir,filepath.Base(path)),mode)
+			if err != nil {
+				return fmt.Errorf("Tool (%s) could not publish output (%s): %s",e.ToolInstance.Name,param.Name,err.Error())
+			}
+		}
+	}
+	return nil
+}
 func (e *ToolExecutor) CreateOutputFile(name string,ext string) (string,error) {
 
 	outputFile := strings.Join([]string{e.ToolInstance.ID,name},"_")
@@ -227,15 +312,134 @@ func (e *ToolExecutor) CreateOutputFile(name string,ext string) (string,error) {
 	if err != nil {
 		return "" , err
 	}
-	os.Mkdir(toolOutputDir,config.FILE_MODE_WRITABLE_PERM)
+	os.MkdirAll(toolOutputDir,config.FILE_MODE_WRITABLE_PERM)
 	outputFile = strings.Join([]string{toolOutputDir,outputFile},"/")
 	return outputFile , nil
 
 }
 
+// getOutputName keeps the output files of every iteration of a looping step apart, as the iterations might run at the same time
+func (e *ToolExecutor) getOutputName(name string) string {
+	if loopIndex , ok := e.flowConfig["loop_index"]; ok {
+		return fmt.Sprintf("%s_%v",name,loopIndex)
+	}
+	return name
+}
 func (e *ToolExecutor) SetExplain(explain bool){
 	e.explain = explain
 }
+// SetResourceBudget makes every attempt of the tool wait until its caps fit into the given budget
+func (e *ToolExecutor) SetResourceBudget(budget *ResourceBudget) {
+	e.budget = budget
+}
+
+// SetImagePuller shares the images pulled during the run, so every image is pulled once for all the steps using it
+func (e *ToolExecutor) SetImagePuller(images *ImagePuller) {
+	e.images = images
+}
+// pullImage makes the image of the tool available according to its pull policy
+func (e *ToolExecutor) pullImage(containerConfig *models.ContainerConfig) error {
+	policy , err := getPullPolicy(e.ToolInstance.PullPolicy,e.flowConfig)
+	if err != nil {
+		return err
+	}
+	if e.images == nil {
+		e.images = NewImagePuller()
+	}
+	return e.images.Pull(e.ToolInstance.ImageId,containerConfig,policy,e.Log)
+}
+// SetStepCache allows the tool to be restored from a previous identical run instead of being executed
+func (e *ToolExecutor) SetStepCache(cache *StepCache) {
+	e.cache = cache
+}
+// getCacheKey returns the cache key of the current execution or an empty key if the tool should not be cached
+func (e *ToolExecutor) getCacheKey(toolCommand string , toolConfig models.FlowConfig) string {
+	if e.cache == nil || e.explain || !e.ToolInstance.IsCacheable() {
+		return ""
+	}
+	imageId := ""
+	if e.isDockerized() {
+		var err error
+		imageId , err = e.dockerManager.GetImageId(e.ToolInstance.ImageId)
+		if err != nil {
+			e.Log(fmt.Sprintf("Tool (%s) will not be cached, unable to inspect its image: %s",e.ToolInstance.Name,err.Error()))
+			return ""
+		}
+	}
+	key , err := e.cache.Key(toolCommand,e.getEnv(toolConfig),imageId,e.ToolInstance.Tool,toolConfig)
+	if err != nil {
+		e.Log(fmt.Sprintf("Tool (%s) will not be cached: %s",e.ToolInstance.Name,err.Error()))
+		return ""
+	}
+	return key
+}
+// restoreFromCache fills the tool configuration from a previous successful run with the same cache key if any
+func (e *ToolExecutor) restoreFromCache(key string , toolConfig models.FlowConfig) bool {
+	if len(key) == 0 {
+		return false
+	}
+	location , savedConfig , ok := e.cache.Lookup(key)
+	if !ok {
+		return false
+	}
+	_ , toolDir , err := e.GetToolOutputDir()
+	if err != nil {
+		return false
+	}
+	err = e.cache.Restore(location,toolDir)
+	if err != nil {
+		e.Log(fmt.Sprintf("Tool (%s) could not be restored from the cache: %s",e.ToolInstance.Name,err.Error()))
+		return false
+	}
+	// Variables created by the scripts of the previous run are restored as well
+	restored := models.FlowConfig{}
+	restored.Fill(toolConfig)
+	for k , v := range savedConfig {
+		if _ , exists := restored[k]; !exists {
+			restored[k] = v
+		}
+	}
+	// The restored outputs are verified and their globs expanded as if the tool has run, the tool runs again if they do not check out
+	err = e.verifyOutputs(restored,toolDir)
+	if err != nil {
+		e.Log(fmt.Sprintf("Tool (%s) could not be restored from the cache: %s",e.ToolInstance.Name,err.Error()))
+		return false
+	}
+	toolConfig.Fill(restored)
+	toolConfig["exitCode"] = 0
+	toolConfig["status"] = true
+	toolConfig[models.STEP_STATE_KEY] = models.STEP_STATE_SUCCEEDED
+	toolConfig[models.STEP_CACHED_KEY] = true
+	toolConfig[models.STEP_CACHE_KEY] = key
+	delete(toolConfig,"self_dir")
+	for _ , param := range e.ToolInstance.Config {
+		delete(toolConfig,param.Name)
+	}
+	e.Log(fmt.Sprintf("Tool (%s) has been restored from the cache of a previous run (%s).",e.ToolInstance.Name,location))
+	return true
+}
+// cacheResult saves the configuration of a successful execution under the given cache key
+func (e *ToolExecutor) cacheResult(key string , toolConfig models.FlowConfig) {
+	if len(key) == 0 || toolConfig["status"] != true {
+		return
+	}
+	toolConfig[models.STEP_CACHE_KEY] = key
+	savedConfig := models.FlowConfig(toolConfig.GetAsMap())
+	delete(savedConfig,"self_dir")
+	delete(savedConfig,models.STEP_ATTEMPTS_KEY)
+	delete(savedConfig,models.STEP_USAGE_KEY)
+	for _ , param := range e.ToolInstance.Config {
+		delete(savedConfig,param.Name)
+	}
+	_ , toolDir , err := e.GetToolOutputDir()
+	if err != nil {
+		return
+	}
+	err = e.cache.Store(key,toolDir,savedConfig)
+	if err != nil {
+		e.Log(fmt.Sprintf("Tool (%s) could not be cached: %s",e.ToolInstance.Name,err.Error()))
+	}
+}
 
 func (e *ToolExecutor) init(flowConfig models.FlowConfig) error {
 	e.ContainerManager = nil
@@ -250,18 +454,10 @@ func (e *ToolExecutor) init(flowConfig models.FlowConfig) error {
 	e.hostOutputDir = fmt.Sprintf("%v",e.flowConfig[config.WF_INSTANCE_OUTDIR])
 	e.exprManager = &expr.ExprManager{}
 	// initialize the tool logger
-	logFileName , err := e.CreateOutputFile("logs","logs")
-	if err != nil {
-		return err
-	}
-	e.toolLogger = &log.Logger{}
-	e.toolLogger.SetPrefix(fmt.Sprintf("%v: ",config.BIOFLOWS_DISPLAY_NAME))
-	file , err := os.Create(logFileName)
+	e.toolLogger , _ , err = e.newLogger(e.getOutputName("logs"))
 	if err != nil {
-		fmt.Printf("Can't Create Tool (%s) log file %s",e.ToolInstance.Name, logFileName)
 		return err
 	}
-	e.toolLogger.SetOutput(file)
 	//initialize Docker
 	hostConfig := &container.HostConfig{}
 	hostConfig.Binds = append(hostConfig.Binds,fmt.Sprintf("%s:%s",e.hostOutputDir,
@@ -278,10 +474,179 @@ func (e *ToolExecutor) init(flowConfig models.FlowConfig) error {
 
 	return nil
 }
+// newLogger creates a logger writing into the log file of the tool with the given name
+func (e *ToolExecutor) newLogger(name string) (*log.Logger,*os.File,error) {
+	logFileName , err := e.CreateOutputFile(name,"logs")
+	if err != nil {
+		return nil , nil , err
+	}
+	logger := &log.Logger{}
+	logger.SetPrefix(fmt.Sprintf("%v: ",config.BIOFLOWS_DISPLAY_NAME))
+	file , err := os.Create(logFileName)
+	if err != nil {
+		fmt.Printf("Can't Create Tool (%s) log file %s",e.ToolInstance.Name, logFileName)
+		return nil , nil , err
+	}
+	logger.SetOutput(file)
+	return logger , file , nil
+}
 func (e *ToolExecutor) Log(logs ...interface{}) {
 	e.toolLogger.Println(logs...)
 	fmt.Println(logs...)
 }
+// setState records why the tool has (not) succeeded next to its boolean status
+func (e *ToolExecutor) setState(toolConfig models.FlowConfig) {
+	ctx := e.stepCtx
+	if ctx == nil {
+		ctx = e.ctx
+	}
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		// A killed process has no exit code of its own
+		toolConfig["status"] = false
+		toolConfig["exitCode"] = models.EXIT_CODE_TIMEOUT
+		toolConfig[models.STEP_STATE_KEY] = models.STEP_STATE_TIMEOUT
+		return
+	case context.Canceled:
+		toolConfig["status"] = false
+		toolConfig[models.STEP_STATE_KEY] = models.STEP_STATE_CANCELLED
+		return
+	}
+	if status , ok := toolConfig["status"].(bool); ok && status {
+		toolConfig[models.STEP_STATE_KEY] = models.STEP_STATE_SUCCEEDED
+	}else{
+		toolConfig[models.STEP_STATE_KEY] = models.STEP_STATE_FAILED
+	}
+}
+// getEnv renders the environment variables of the tool against the given configuration, sorted by their names
+func (e *ToolExecutor) getEnv(toolConfig models.FlowConfig) []string {
+	names := make([]string,0,len(e.ToolInstance.Env))
+	for name := range e.ToolInstance.Env {
+		names = append(names,name)
+	}
+	sort.Strings(names)
+	env := make([]string,0,len(names))
+	for _ , name := range names {
+		env = append(env,fmt.Sprintf("%s=%s",name,e.exprManager.Render(e.ToolInstance.Env[name],toolConfig)))
+	}
+	return env
+}
+/*
+	verifyOutputs makes sure that every declared file output exists and is not empty, glob outputs are expanded into
+	the list of the non empty files they match. A missing output fails the tool unless it is optional.
+	Relative outputs are resolved against the given working directory of the tool, like the command which has produced them.
+ */
+func (e *ToolExecutor) verifyOutputs(toolConfig models.FlowConfig , workDir string) error {
+	errs := make(models.ParameterErrors,0)
+	for _ , param := range e.ToolInstance.Outputs {
+		switch param.GetType() {
+		case models.PARAM_TYPE_FILE:
+			location := resolvePath(workDir,fmt.Sprintf("%v",toolConfig[param.Name]))
+			info , err := os.Stat(location)
+			if (err != nil || info.IsDir() || info.Size() == 0) && !param.Optional {
+				errs = append(errs,fmt.Errorf("output (%s) is missing or empty: %s",param.Name,location))
+			}
+		case models.PARAM_TYPE_GLOB:
+			pattern := resolvePath(workDir,fmt.Sprintf("%v",toolConfig[param.Name]))
+			matches , err := filepath.Glob(pattern)
+			if err != nil {
+				errs = append(errs,fmt.Errorf("output (%s) has an invalid glob (%s): %s",param.Name,pattern,err.Error()))
+				continue
+			}
+			files := make([]interface{},0)
+			for _ , match := range matches {
+				if info , err := os.Stat(match); err == nil && !info.IsDir() && info.Size() > 0 {
+					files = append(files,match)
+				}
+			}
+			toolConfig[param.Name] = files
+			if len(files) == 0 && !param.Optional {
+				errs = append(errs,fmt.Errorf("output (%s) does not match any file: %s",param.Name,pattern))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+// resolvePath returns the given path relative to the working directory unless it is absolute
+func resolvePath(workDir string , location string) string {
+	if len(workDir) == 0 || filepath.IsAbs(location) {
+		return location
+	}
+	return filepath.Join(workDir,location)
+}
+// failBeforeRun records the tool as failed when it could not start, e.g. because of invalid parameters or failing before scripts
+func (e *ToolExecutor) failBeforeRun(toolConfig models.FlowConfig) {
+	if toolConfig == nil {
+		return
+	}
+	toolConfig["status"] = false
+	toolConfig["exitCode"] = 1
+	e.setState(toolConfig)
+}
+// startStep bounds the next execution of the tool by its timeout directive if any
+func (e *ToolExecutor) startStep() context.CancelFunc {
+	timeout , err := e.ToolInstance.GetTimeout()
+	if err != nil {
+		e.Log(fmt.Sprintf("Tool (%s) has an invalid timeout: %s",e.ToolInstance.Name,err.Error()))
+	}
+	if timeout <= 0 {
+		e.stepCtx = e.ctx
+		return func(){}
+	}
+	var cancel context.CancelFunc
+	e.stepCtx , cancel = context.WithTimeout(e.ctx,timeout)
+	return cancel
+}
+// skipOnConditions evaluates the conditions of the tool and marks the tool as skipped if any of them is not satisfied
+func (e *ToolExecutor) skipOnConditions(toolConfig models.FlowConfig) bool {
+	scriptManager := &scripts.JSScriptManager{}
+	satisfied , err := scriptManager.EvaluateConditions(e.ToolInstance.Conditions,toolConfig)
+	if err != nil {
+		e.Log(fmt.Sprintf("Tool (%s): %s",e.ToolInstance.Name,err.Error()))
+		toolConfig["exitCode"] = 1
+		toolConfig["status"] = false
+		toolConfig[models.STEP_STATE_KEY] = models.STEP_STATE_FAILED
+		return true
+	}
+	if satisfied {
+		return false
+	}
+	e.Log(fmt.Sprintf("Tool (%s) has been skipped, its conditions are not satisfied.",e.ToolInstance.Name))
+	toolConfig["exitCode"] = 0
+	toolConfig["status"] = false
+	toolConfig[models.STEP_STATE_KEY] = models.STEP_STATE_SKIPPED
+	return true
+}
+/*
+	getCommand renders the command of the tool against the given configuration, it returns the command to display
+	and the arguments of the process running it. Exec-form commands are rendered argument by argument and run without any shell,
+	command lines are run by the shell of the tool or split on white spaces when the shell is none.
+ */
+func (e *ToolExecutor) getCommand(toolConfig models.FlowConfig) (string,[]string,error) {
+	if e.ToolInstance.Command.IsExec() {
+		args := make([]string,len(e.ToolInstance.Command.Args))
+		for idx , arg := range e.ToolInstance.Command.Args {
+			args[idx] = e.exprManager.Render(arg,toolConfig)
+		}
+		return fmt.Sprintf("%q",args) , args , nil
+	}
+	toolCommand := e.exprManager.Render(fmt.Sprintf("%v",toolConfig["command"]),toolConfig)
+	shell , err := models.GetShell(e.ToolInstance.Shell)
+	if err != nil {
+		return toolCommand , nil , err
+	}
+	if shell != models.SHELL_NONE {
+		return toolCommand , []string{shell,"-c",toolCommand} , nil
+	}
+	args := strings.Fields(toolCommand)
+	if len(args) == 0 {
+		return toolCommand , nil , fmt.Errorf("Tool (%s) has no command to run",e.ToolInstance.Name)
+	}
+	return toolCommand , args , nil
+}
 func (e *ToolExecutor) isDockerized() bool {
 	result := e.ToolInstance.ImageId != "" && len(e.ToolInstance.ImageId) > 1
 	return result
@@ -290,6 +655,7 @@ func (e *ToolExecutor) execute() (models.FlowConfig,error) {
 	//prepare parameters
 	toolConfig, err := e.executeBeforeScripts()
 	if err != nil {
+		e.failBeforeRun(toolConfig)
 		return toolConfig,err
 	}
 	if toolConfig["impede"] == true{
@@ -298,65 +664,111 @@ func (e *ToolExecutor) execute() (models.FlowConfig,error) {
 		toolConfig["status"] = true
 		return toolConfig,nil
 	}
+	if e.skipOnConditions(toolConfig) {
+		return toolConfig , nil
+	}
 	//Defer the notification till the end of the execute method
-	defer e.notify(e.ToolInstance)
-	toolCommandStr := fmt.Sprintf("%v",toolConfig["command"])
-	toolCommand := e.exprManager.Render(toolCommandStr,toolConfig)
+	toolCommand , commandArgs , commandErr := e.getCommand(toolConfig)
 	toolConfigKey, _ , _ := e.GetToolOutputDir()
 	var exitCode int
 	var toolErr error
-	var outputBytes []byte
-	var errorBytes []byte
+	var output *stepOutput
+	var cacheKey string
+	var usage models.ResourceUsage
+	var scratchDir string
 	var tempContainerConfig *models.ContainerConfig = nil
 	if e.ToolInstance.ContainerConfig != nil {
 		tempContainerConfig = e.ToolInstance.ContainerConfig
 	}else{
 		tempContainerConfig = e.pipelineContainerConfig
 	}
+	if commandErr != nil {
+		e.Log(commandErr.Error())
+		toolErr = commandErr
+		exitCode = 1
+		goto AfterScriptsAndExit
+	}
 	e.Log(fmt.Sprintf("RunScript Command : %s",toolCommand))
 	if e.explain{
 		fmt.Printf("Explain => Tool Name: %s , Command: %s\n",e.ToolInstance.ID,toolCommand)
 		goto AfterScriptsAndExit
 	}
+	if e.stepCtx.Err() != nil {
+		toolErr = e.stepCtx.Err()
+		goto AfterScriptsAndExit
+	}
 	if e.isDockerized() {
-		var imageURL string
-		if tempContainerConfig == nil {
-			imageURL = fmt.Sprintf("%s/%s",dockcontainer.DOCKER_REPOSITORY,e.ToolInstance.ImageId)
-		}else{
-			imageURL = fmt.Sprintf("%s/%s",tempContainerConfig.URL,e.ToolInstance.ImageId)
-		}
-		//first try to pull the image
-		output , err := e.dockerManager.PullImage(imageURL,tempContainerConfig)
-		if err != nil {
-			return nil , err
-		}
-		//Log the output
-		e.Log(output)
-
-		out,outErr,toolErr := e.dockerManager.RunContainer(toolConfigKey,e.ToolInstance.ImageId,[]string{
-			"bash",
-			"-c",
-			toolCommand,
-		},false)
+		//first make sure the image is available
+		toolErr = e.pullImage(tempContainerConfig)
 		if toolErr != nil {
-			errorBytes = []byte(toolErr.Error())
+			e.Log(toolErr.Error())
 			exitCode = 1
-		}else{
-			exitCode = 0
+			goto AfterScriptsAndExit
 		}
-		if out != nil {
-			outputBytes = out.Bytes()
+		e.dockerManager.Configure(tempContainerConfig,e.ToolInstance.Caps)
+	}
+	// The image has to be pulled first, so the cache key reflects the version which runs
+	cacheKey = e.getCacheKey(toolCommand,toolConfig)
+	if e.restoreFromCache(cacheKey,toolConfig) {
+		// Restored outputs are published as if the tool has run
+		if err := e.publishOutputs(toolConfig,fmt.Sprintf("%v",toolConfig[toolConfigKey])); err != nil {
+			e.Log(err.Error())
+			toolConfig["exitCode"] = 1
+			toolConfig["status"] = false
+			e.setState(toolConfig)
+			return toolConfig , err
 		}
-		if outErr != nil {
-			errorBytes = outErr.Bytes()
+		return toolConfig , nil
+	}
+	scratchDir = e.GetScratchDir()
+	toolErr = e.createScratchDir(scratchDir)
+	if toolErr != nil {
+		e.Log(toolErr.Error())
+		exitCode = 1
+		goto AfterScriptsAndExit
+	}
+	// The output of the command is streamed into the files of the tool while it runs
+	output , toolErr = e.openOutput(toolConfig,fmt.Sprintf("%v",toolConfig[toolConfigKey]),e.getOutputName("stdout"),e.getOutputName("stderr"))
+	defer output.Close()
+	if toolErr != nil {
+		e.Log(toolErr.Error())
+		exitCode = 1
+		goto AfterScriptsAndExit
+	}
+	e.ToolInstance.StartTime = time.Now()
+	if e.isDockerized() {
+		var containerUsage dockcontainer.ContainerUsage
+		exitCode , containerUsage , toolErr = e.dockerManager.RunContainerContext(e.stepCtx,toolConfigKey,e.ToolInstance.ImageId,commandArgs,
+			e.getEnv(toolConfig),output.Stdout,output.Stderr,false)
+		usage.UserTime , usage.SystemTime , usage.PeakRSS = containerUsage.UserTime , containerUsage.SystemTime , containerUsage.PeakMemory
+		if toolErr != nil {
+			fmt.Fprintln(output.Stderr,toolErr.Error())
+			if exitCode == 0 {
+				exitCode = 1
+			}
 		}
 	}else{
 
-		executor := &process.CommandExecutor{Command: toolCommand,CommandDir: fmt.Sprintf("%v",toolConfig[toolConfigKey])}
+		executor := &process.CommandExecutor{Command: toolCommand,Args: commandArgs,CommandDir: fmt.Sprintf("%v",toolConfig[toolConfigKey]),Env: e.getEnv(toolConfig),
+			Stdout: output.Stdout,Stderr: output.Stderr}
 		executor.Init()
-		exitCode , toolErr  = executor.Run()
-		outputBytes = executor.GetOutput().Bytes()
-		errorBytes = executor.GetError().Bytes()
+		exitCode , toolErr  = executor.RunContext(e.stepCtx)
+		usage.UserTime , usage.SystemTime , usage.PeakRSS = executor.GetUsage()
+	}
+	e.ToolInstance.EndTime = time.Now()
+	usage.WallTime = e.ToolInstance.EndTime.Sub(e.ToolInstance.StartTime)
+	toolConfig[models.STEP_USAGE_KEY] = usage.ToMap()
+	// A command which has succeeded should have produced all of its declared outputs
+	if exitCode == 0 && toolErr == nil {
+		toolErr = e.verifyOutputs(toolConfig,fmt.Sprintf("%v",toolConfig[toolConfigKey]))
+		if toolErr == nil {
+			toolErr = e.publishOutputs(toolConfig,fmt.Sprintf("%v",toolConfig[toolConfigKey]))
+		}
+		if toolErr != nil {
+			e.Log(toolErr.Error())
+			fmt.Fprintln(output.Stderr,toolErr.Error())
+			exitCode = 1
+		}
 	}
 	AfterScriptsAndExit:
 	toolConfig , err = e.executeAfterScripts(toolConfig)
@@ -364,12 +776,17 @@ func (e *ToolExecutor) execute() (models.FlowConfig,error) {
 	if toolErr != nil {
 		toolConfig["status"] = false
 	}
+	if toolErr == dockcontainer.ErrOOMKilled {
+		toolConfig[models.STEP_FAILURE_REASON_KEY] = models.FAILURE_REASON_OOM_KILLED
+	}
 	if exitCode == 0 {
 		toolConfig["status"] = true
 	}
 	if exitCode > 0 {
 		toolConfig["status"] = false
 	}
+	e.setState(toolConfig)
+	e.cleanScratchDir(scratchDir,toolConfig)
 	delete(toolConfig,"self_dir")
 
 	defer func(){
@@ -384,42 +801,37 @@ func (e *ToolExecutor) execute() (models.FlowConfig,error) {
 		}
 	}()
 	defer e.Log(fmt.Sprintf("Tool: %s has finished.",e.ToolInstance.Name))
-	if e.ToolInstance.Shadow{
-		return toolConfig,toolErr
-	}
-	//Create output file for the output of this tool
-	toolOutputFile , err := e.CreateOutputFile("stdout","out")
-	if err != nil {
-		return toolConfig,err
-	}
-	err = helpers.WriteOrAppend(toolOutputFile,outputBytes,config.FILE_MODE_WRITABLE_PERM)
-	if err != nil {
-		return toolConfig,err
-	}
-	//Create err file for this tool
-	toolErrFile , err := e.CreateOutputFile("stderr","err")
-	if err != nil {
-		return toolConfig,err
-	}
-	err = helpers.WriteOrAppend(toolErrFile,errorBytes,config.FILE_MODE_WRITABLE_PERM)
-	if err != nil {
-		return toolConfig,err
+	// Every output has to be written before the result is cached
+	output.Close()
+	if toolErr == nil {
+		e.cacheResult(cacheKey,toolConfig)
 	}
 	//Delete the temporary mapped self_dir key from the configuration
 	return toolConfig,toolErr
 }
 func (e *ToolExecutor) RunToolLoop(t *models.ToolInstance , workflowConfig models.FlowConfig)  (models.FlowConfig,error) {
+	return e.RunToolLoopContext(context.Background(),t,workflowConfig)
+}
+func (e *ToolExecutor) RunToolLoopContext(ctx context.Context,t *models.ToolInstance , workflowConfig models.FlowConfig)  (models.FlowConfig,error) {
+	e.ctx = ctx
 	e.ToolInstance = t
 	err := e.init(workflowConfig)
 	if err != nil {
 		return nil , err
 	}
 	e.Log(fmt.Sprintf("Tool (%s) is prepared successfully. ",t.Name))
-	return e.executeLoop()
+	e.notify(models.NOTIFY_ON_START,nil)
+	// The timeout of a looping tool covers all of its iterations
+	cancel := e.startStep()
+	defer cancel()
+	toolConfig , err := e.executeLoop()
+	e.notify(getNotificationEvent(toolConfig),toolConfig)
+	return toolConfig , err
 }
 func (e *ToolExecutor) executeLoop()  (models.FlowConfig,error) {
 	toolConfig , err := e.executeBeforeScripts()
 	if err != nil {
+		e.failBeforeRun(toolConfig)
 		return toolConfig , err
 	}
 	if toolConfig["impede"] == true{
@@ -428,114 +840,200 @@ func (e *ToolExecutor) executeLoop()  (models.FlowConfig,error) {
 		toolConfig["status"] = true
 		return toolConfig,nil
 	}
-	defer e.notify(e.ToolInstance)
-	if len(e.ToolInstance.LoopVar) == 0 {
-		errStr := "Tool is loop but no loop variable has been defined.. aborting..."
-		e.Log(fmt.Sprintf(errStr))
-		return toolConfig , fmt.Errorf(errStr)
+	if e.skipOnConditions(toolConfig) {
+		return toolConfig , nil
+	}
+	bindings , err := models.Scatter(toolConfig,e.ToolInstance.GetLoopVars(),e.ToolInstance.ScatterMode)
+	if err != nil {
+		e.Log(fmt.Sprintf("Tool (%s): %s.. aborting...",e.ToolInstance.Name,err.Error()))
+		return toolConfig , err
 	}
 	var exitCode int
 	var toolErr error
-	var outputBytes []byte
-	var errorBytes []byte
-	if loop_elements , ok := toolConfig[e.ToolInstance.LoopVar]; ok {
-		if elements , islist := loop_elements.([]interface{}); islist {
-			for idx , el := range elements {
-				toolConfig[fmt.Sprintf("%s_item",e.ToolInstance.LoopVar)] = el
-				toolConfig[fmt.Sprintf("loop_index")] = idx
-				toolCommandStr := fmt.Sprintf("%v",toolConfig["command"])
-				toolCommand := e.exprManager.Render(toolCommandStr,toolConfig)
-				toolConfigKey, _ , _ := e.GetToolOutputDir()
-
-				var tempContainerConfig *models.ContainerConfig = nil
-				if e.ToolInstance.ContainerConfig != nil {
-					tempContainerConfig = e.ToolInstance.ContainerConfig
-				}else{
-					tempContainerConfig = e.pipelineContainerConfig
+	{
+		{
+			toolConfigKey, _ , _ := e.GetToolOutputDir()
+			var tempContainerConfig *models.ContainerConfig = nil
+			if e.ToolInstance.ContainerConfig != nil {
+				tempContainerConfig = e.ToolInstance.ContainerConfig
+			}else{
+				tempContainerConfig = e.pipelineContainerConfig
+			}
+			if e.isDockerized() && !e.explain {
+				//first make sure the image is available once for all iterations
+				err := e.pullImage(tempContainerConfig)
+				if err != nil {
+					e.Log(err.Error())
+					e.failBeforeRun(toolConfig)
+					return toolConfig , err
+				}
+				e.dockerManager.Configure(tempContainerConfig,e.ToolInstance.Caps)
+			}
+			// Every iteration gets its own directory inside the scratch directory of the loop
+			scratchDir := e.GetScratchDir()
+			if !e.explain {
+				err = e.createScratchDir(scratchDir)
+				if err != nil {
+					e.Log(err.Error())
+					e.failBeforeRun(toolConfig)
+					return toolConfig , err
+				}
+			}
+			// Every iteration keeps its own results, they are combined in the order of the elements
+			exitCodes := make([]int,len(bindings))
+			toolErrs := make([]error,len(bindings))
+			results := make([]models.FlowConfig,len(bindings))
+			usages := make([]models.ResourceUsage,len(bindings))
+			e.ToolInstance.StartTime = time.Now()
+			runIterations(e.stepCtx,e.ToolInstance.Parallelism,len(bindings), func(idx int) bool {
+				iterationConfig := models.FlowConfig{}
+				iterationConfig.Fill(toolConfig)
+				iterationConfig.Fill(bindings[idx])
+				iterationConfig[fmt.Sprintf("loop_index")] = idx
+				if len(scratchDir) > 0 {
+					iterationConfig["scratch_dir"] = filepath.Join(scratchDir,fmt.Sprintf("%d",idx))
 				}
-				e.Log(fmt.Sprintf("RunScript Command : %s",toolCommand))
+				results[idx] = models.FlowConfig{}
+				for _ , param := range e.ToolInstance.Outputs {
+					results[idx][param.Name] = e.exprManager.Render(param.GetParamValue(),iterationConfig)
+				}
+				// Every iteration keeps its own log and state, named by its index
+				iterationLogger , logFile , err := e.newLogger(fmt.Sprintf("logs_%d",idx))
+				if err != nil {
+					e.Log(err.Error())
+					toolErrs[idx] = err
+					exitCodes[idx] = 1
+					return false
+				}
+				defer logFile.Close()
+				iterationLog := func(logs ...interface{}) {
+					iterationLogger.Println(logs...)
+					fmt.Println(logs...)
+				}
+				iterationStart := time.Now()
+				defer func(){
+					results[idx]["loop_index"] = idx
+					results[idx]["exitCode"] = exitCodes[idx]
+					results[idx]["status"] = exitCodes[idx] == 0 && toolErrs[idx] == nil
+					if toolErrs[idx] == dockcontainer.ErrOOMKilled {
+						results[idx][models.STEP_FAILURE_REASON_KEY] = models.FAILURE_REASON_OOM_KILLED
+					}
+					if !e.explain {
+						usages[idx].WallTime = time.Since(iterationStart)
+						results[idx][models.STEP_USAGE_KEY] = usages[idx].ToMap()
+					}
+					e.setState(results[idx])
+				}()
+				toolCommand , commandArgs , err := e.getCommand(iterationConfig)
+				if err != nil {
+					iterationLog(err.Error())
+					toolErrs[idx] = err
+					exitCodes[idx] = 1
+					return false
+				}
+				iterationLog(fmt.Sprintf("RunScript Command : %s",toolCommand))
 				if e.explain {
 					fmt.Printf("Explain => Tool Name: %s , Command: %s\n",e.ToolInstance.ID,toolCommand)
-					goto AfterScriptsAndExit
+					return true
 				}
-				if e.isDockerized() {
-					var imageURL string
-					if tempContainerConfig == nil {
-						imageURL = fmt.Sprintf("%s/%s",dockcontainer.DOCKER_REPOSITORY,e.ToolInstance.ImageId)
-					}else{
-						imageURL = fmt.Sprintf("%s/%s",tempContainerConfig.URL,e.ToolInstance.ImageId)
-					}
-					//first try to pull the image
-					output , err := e.dockerManager.PullImage(imageURL,tempContainerConfig)
+				if len(scratchDir) > 0 {
+					err = os.MkdirAll(fmt.Sprintf("%v",iterationConfig["scratch_dir"]),config.FILE_MODE_WRITABLE_PERM)
 					if err != nil {
-						return nil , err
+						iterationLog(err.Error())
+						toolErrs[idx] = err
+						exitCodes[idx] = 1
+						return false
 					}
-					//Log the output
-					e.Log(output)
-					out,outErr,toolErr := e.dockerManager.RunContainer(toolConfigKey,e.ToolInstance.ImageId,[]string{
-						"bash",
-						"-c",
-						toolCommand,
-					},false)
-					if toolErr != nil {
-						errorBytes = append(errorBytes,[]byte(toolErr.Error())...)
-						exitCode = 1
-					}else{
-						exitCode = 0
-					}
-					if out != nil {
-						outputBytes = append(outputBytes,out.Bytes()...)
-					}
-					if outErr != nil {
-						errorBytes = append(errorBytes,outErr.Bytes()...)
+				}
+				// Every iteration streams its output into its own files
+				output , err := e.openOutput(results[idx],fmt.Sprintf("%v",iterationConfig[toolConfigKey]),fmt.Sprintf("stdout_%d",idx),fmt.Sprintf("stderr_%d",idx))
+				defer output.Close()
+				if err != nil {
+					iterationLog(err.Error())
+					toolErrs[idx] = err
+					exitCodes[idx] = 1
+					return false
+				}
+				if e.isDockerized() {
+					var containerUsage dockcontainer.ContainerUsage
+					exitCodes[idx] , containerUsage , toolErrs[idx] = e.dockerManager.RunContainerContext(e.stepCtx,toolConfigKey,e.ToolInstance.ImageId,commandArgs,
+						e.getEnv(iterationConfig),output.Stdout,output.Stderr,false)
+					usages[idx] = models.ResourceUsage{UserTime: containerUsage.UserTime,SystemTime: containerUsage.SystemTime,PeakRSS: containerUsage.PeakMemory}
+					if toolErrs[idx] != nil {
+						fmt.Fprintln(output.Stderr,toolErrs[idx].Error())
+						if exitCodes[idx] == 0 {
+							exitCodes[idx] = 1
+						}
 					}
 				}else{
-
-					executor := &process.CommandExecutor{Command: toolCommand,CommandDir: fmt.Sprintf("%v",toolConfig[toolConfigKey])}
+					executor := &process.CommandExecutor{Command: toolCommand,Args: commandArgs,CommandDir: fmt.Sprintf("%v",iterationConfig[toolConfigKey]),Env: e.getEnv(iterationConfig),
+						Stdout: output.Stdout,Stderr: output.Stderr}
 					executor.Init()
-					exitCode , toolErr  = executor.Run()
-					outputBytes = append(outputBytes,executor.GetOutput().Bytes()...)
-					errorBytes = append(errorBytes,executor.GetError().Bytes()...)
+					exitCodes[idx] , toolErrs[idx]  = executor.RunContext(e.stepCtx)
+					usages[idx].UserTime , usages[idx].SystemTime , usages[idx].PeakRSS = executor.GetUsage()
 				}
-
+				if exitCodes[idx] == 0 && toolErrs[idx] == nil {
+					toolErrs[idx] = e.verifyOutputs(results[idx],fmt.Sprintf("%v",iterationConfig[toolConfigKey]))
+					if toolErrs[idx] == nil {
+						publishConfig := models.FlowConfig{}
+						publishConfig.Fill(iterationConfig)
+						publishConfig.Fill(results[idx])
+						toolErrs[idx] = e.publishOutputs(publishConfig,fmt.Sprintf("%v",iterationConfig[toolConfigKey]))
+					}
+					if toolErrs[idx] != nil {
+						iterationLog(toolErrs[idx].Error())
+						fmt.Fprintln(output.Stderr,toolErrs[idx].Error())
+						exitCodes[idx] = 1
+					}
+				}
+				return exitCodes[idx] == 0 && toolErrs[idx] == nil
+			})
+			e.ToolInstance.EndTime = time.Now()
+			// Iterations may run in parallel, so the cpu times are summed up while the wall time covers the whole loop
+			if !e.explain {
+				toolConfig[models.STEP_USAGE_KEY] = gatherUsage(e.ToolInstance.EndTime.Sub(e.ToolInstance.StartTime),results).ToMap()
+			}
+			// The state of every iteration is kept in the order of the iterations, those which did not run are left empty
+			iterations := make([]interface{},len(results))
+			for idx , result := range results {
+				if result != nil {
+					iterations[idx] = result.GetAsMap()
+				}
+			}
+			toolConfig[models.STEP_ITERATIONS_KEY] = iterations
+			// The first failing iteration decides the exit code of the whole loop
+			for idx := range bindings {
+				if exitCode == 0 && toolErr == nil {
+					exitCode = exitCodes[idx]
+					toolErr = toolErrs[idx]
+				}
+			}
+			if toolErr == nil && e.stepCtx.Err() != nil {
+				toolErr = e.stepCtx.Err()
+			}
+			for name , values := range gatherOutputs(e.ToolInstance.Outputs,results) {
+				toolConfig[name] = values
 			}
 		}
 	}
-	AfterScriptsAndExit:
 	toolConfig , err = e.executeAfterScripts(toolConfig)
 	toolConfig["exitCode"] = exitCode
 	if toolErr != nil {
 		toolConfig["status"] = false
 	}
+	if toolErr == dockcontainer.ErrOOMKilled {
+		toolConfig[models.STEP_FAILURE_REASON_KEY] = models.FAILURE_REASON_OOM_KILLED
+	}
 	if exitCode == 0 {
 		toolConfig["status"] = true
 	}
 	if exitCode > 0 {
 		toolConfig["status"] = false
 	}
+	e.setState(toolConfig)
+	e.cleanScratchDir(e.GetScratchDir(),toolConfig)
 	delete(toolConfig,"self_dir")
 	defer e.Log(fmt.Sprintf("Tool: %s has finished.",e.ToolInstance.Name))
-	if e.ToolInstance.Shadow{
-		return toolConfig,toolErr
-	}
-	//Create output file for the output of this tool
-	toolOutputFile , err := e.CreateOutputFile("stdout","out")
-	if err != nil {
-		return toolConfig,err
-	}
-	err = helpers.WriteOrAppend(toolOutputFile,outputBytes,config.FILE_MODE_WRITABLE_PERM)
-	if err != nil {
-		return toolConfig,err
-	}
-	//Create err file for this tool
-	toolErrFile , err := e.CreateOutputFile("stderr","err")
-	if err != nil {
-		return toolConfig,err
-	}
-	err = helpers.WriteOrAppend(toolErrFile,errorBytes,config.FILE_MODE_WRITABLE_PERM)
-	if err != nil {
-		return toolConfig,err
-	}
 	//Delete the temporary mapped self_dir key from the configuration
 	return toolConfig,toolErr
 
@@ -553,7 +1051,11 @@ func (e *ToolExecutor) SetAttachableVolumes(volumes []models.Parameter) {
 	e.AttachableVolumes = append(e.AttachableVolumes,volumes...)
 }
 func (e *ToolExecutor) Run(t *models.ToolInstance, workflowConfig models.FlowConfig) (models.FlowConfig,error) {
-
+	return e.RunContext(context.Background(),t,workflowConfig)
+}
+// RunContext runs the tool instance and kills its process tree or container once the given context is done.
+func (e *ToolExecutor) RunContext(ctx context.Context,t *models.ToolInstance, workflowConfig models.FlowConfig) (models.FlowConfig,error) {
+	e.ctx = ctx
 	e.ToolInstance = t
 	err := e.init(workflowConfig)
 	if err != nil {
@@ -565,6 +1067,77 @@ func (e *ToolExecutor) Run(t *models.ToolInstance, workflowConfig models.FlowCon
 			e.addAttachableVolume(&volume)
 		}
 	}
-	return e.execute()
+	e.notify(models.NOTIFY_ON_START,nil)
+	toolConfig , err := e.executeWithRetries()
+	e.notify(getNotificationEvent(toolConfig),toolConfig)
+	return toolConfig , err
+}
+// executeWithRetries executes the tool as many times as its retry policy allows until it succeeds,
+// the history of all attempts is recorded in the tool configuration.
+func (e *ToolExecutor) executeWithRetries() (models.FlowConfig,error) {
+	policy := e.ToolInstance.Retry
+	declaredCaps := e.ToolInstance.Caps
+	attempts := make([]interface{},0)
+	// The usage of the step covers all of its attempts
+	total := models.ResourceUsage{}
+	for attempt := 1 ; ; attempt++ {
+		e.ToolInstance.Caps = policy.GetCaps(declaredCaps,attempt)
+		if e.budget != nil {
+			err := e.budget.Acquire(e.ctx,e.ToolInstance.Caps)
+			if err != nil {
+				toolConfig , _ := e.prepareParameters()
+				e.setState(toolConfig)
+				return toolConfig , err
+			}
+		}
+		cancel := e.startStep()
+		toolConfig , err := e.execute()
+		cancel()
+		if e.budget != nil {
+			e.budget.Release(e.ToolInstance.Caps)
+		}
+		if toolConfig == nil {
+			return toolConfig , err
+		}
+		exitCode := toInt(toolConfig["exitCode"])
+		record := map[string]interface{}{
+			"attempt": attempt,
+			"exitCode": exitCode,
+			"status": toolConfig["status"],
+			models.STEP_STATE_KEY: toolConfig[models.STEP_STATE_KEY],
+		}
+		if reason , ok := toolConfig[models.STEP_FAILURE_REASON_KEY]; ok {
+			record[models.STEP_FAILURE_REASON_KEY] = reason
+		}
+		if e.ToolInstance.Caps != nil {
+			record["cpu"] = e.ToolInstance.Caps.CPU
+			record["memory"] = e.ToolInstance.Caps.Memory
+		}
+		if err != nil {
+			record["error"] = err.Error()
+		}
+		if usage , ok := models.GetUsageFromState(toolConfig); ok {
+			record[models.STEP_USAGE_KEY] = toolConfig[models.STEP_USAGE_KEY]
+			total.WallTime += usage.WallTime
+			total.Combine(usage)
+			toolConfig[models.STEP_USAGE_KEY] = total.ToMap()
+		}
+		attempts = append(attempts,record)
+		toolConfig[models.STEP_ATTEMPTS_KEY] = attempts
+		state := toolConfig[models.STEP_STATE_KEY]
+		if (state != models.STEP_STATE_FAILED && state != models.STEP_STATE_TIMEOUT) || !policy.ShouldRetry(attempt,exitCode) {
+			return toolConfig , err
+		}
+		delay := policy.GetDelay(attempt)
+		e.Log(fmt.Sprintf("Tool (%s) has failed with exit code %d, retrying in %v (attempt %d of %d)....",
+			e.ToolInstance.Name,exitCode,delay,attempt+1,policy.Attempts))
+		select {
+		case <- time.After(delay):
+		case <- e.ctx.Done():
+			e.stepCtx = e.ctx
+			e.setState(toolConfig)
+			return toolConfig , e.ctx.Err()
+		}
+	}
 }
 
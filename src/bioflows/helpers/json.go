@@ -0,0 +1,29 @@
+package helpers
+
+import "fmt"
+
+// ToJsonCompatible converts the maps decoded from YAML (map[interface{}]interface{}) into maps with string keys
+// recursively, so the given value can be marshaled into JSON.
+func ToJsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{})
+		for key , val := range v {
+			converted[fmt.Sprintf("%v",key)] = ToJsonCompatible(val)
+		}
+		return converted
+	case map[string]interface{}:
+		converted := make(map[string]interface{})
+		for key , val := range v {
+			converted[key] = ToJsonCompatible(val)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{},len(v))
+		for idx , val := range v {
+			converted[idx] = ToJsonCompatible(val)
+		}
+		return converted
+	}
+	return value
+}
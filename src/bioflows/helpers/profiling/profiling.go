@@ -2,9 +2,13 @@ package profiling
 
 import (
 	"bioflows/models"
+	"bufio"
 	"log"
 	"net"
+	"os"
 	"runtime"
+	"strconv"
+	"strings"
 )
 
 func GetLocalAddress() string {
@@ -23,6 +27,28 @@ func GetCPU() int {
 	return runtime.NumCPU()
 }
 
+// GetTotalMemory returns the total physical memory of the current node in Mega Bytes, or zero if it can't be determined
+func GetTotalMemory() int {
+	file , err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			// MemTotal is reported in kB
+			total , err := strconv.Atoi(fields[1])
+			if err != nil {
+				return 0
+			}
+			return total / 1024
+		}
+	}
+	return 0
+}
+
 func GetCPUProfile() models.CPUProfile {
 	profile := models.CPUProfile{}
 	profile.Memstats = &runtime.MemStats{}
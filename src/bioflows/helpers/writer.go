@@ -0,0 +1,54 @@
+package helpers
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// consoleMutex keeps the lines written by different steps to the console from being interleaved
+var consoleMutex sync.Mutex
+
+// PrefixWriter writes every complete line it receives to the underlying writer preceded by the given prefix
+type PrefixWriter struct {
+	prefix  []byte
+	writer  io.Writer
+	pending []byte
+	mutex   sync.Mutex
+}
+
+func NewPrefixWriter(prefix string , writer io.Writer) *PrefixWriter {
+	return &PrefixWriter{prefix: []byte(prefix),writer: writer}
+}
+
+func (w *PrefixWriter) Write(data []byte) (int,error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.pending = append(w.pending,data...)
+	for {
+		idx := bytes.IndexByte(w.pending,'\n')
+		if idx < 0 {
+			break
+		}
+		w.writeLine(w.pending[:idx+1])
+		w.pending = w.pending[idx+1:]
+	}
+	return len(data) , nil
+}
+
+func (w *PrefixWriter) writeLine(line []byte) {
+	consoleMutex.Lock()
+	defer consoleMutex.Unlock()
+	w.writer.Write(append(append([]byte{},w.prefix...),line...))
+}
+
+// Close writes the last incomplete line if any
+func (w *PrefixWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.pending) > 0 {
+		w.writeLine(append(w.pending,'\n'))
+		w.pending = nil
+	}
+	return nil
+}
@@ -1,5 +1,10 @@
 package managers
 
+import (
+	config2 "bioflows/config"
+	"fmt"
+)
+
 type ContextManager struct {
 	stateManager StateManager
 	remote bool
@@ -24,11 +29,27 @@ func (c *ContextManager) Setup(config map[string]interface{}) error {
 		c.remote = true
 	}else{
 		c.stateManager = &LocalStateManager{}
+		// Persist the states into the output directory so the workflow could be resumed later
+		if outputDir , ok := config[config2.WF_INSTANCE_OUTDIR]; ok && len(fmt.Sprintf("%v",outputDir)) > 0 {
+			fileStateManager , err := GetFileStateManager(fmt.Sprintf("%v",outputDir))
+			if err != nil {
+				return err
+			}
+			c.stateManager = fileStateManager
+		}
 		c.remote = false
 	}
 	return c.stateManager.Setup(config)
 }
 
+// Flush writes the pending states of a state manager which persists them in the background
+func (c *ContextManager) Flush() error {
+	if fileStateManager , ok := c.stateManager.(*FileStateManager); ok {
+		return fileStateManager.Flush()
+	}
+	return nil
+}
+
 func (c *ContextManager) GetStateManager() StateManager{
 	return c.stateManager
 }
@@ -0,0 +1,175 @@
+package managers
+
+import (
+	config2 "bioflows/config"
+	"bioflows/helpers"
+	"bioflows/models"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stateFlushInterval is how long changed states wait before they are written, so many steps finishing together cause a single write
+const stateFlushInterval = time.Second
+
+var (
+	fileStores = make(map[string]*FileStateManager)
+	fileStoresMutex sync.Mutex
+)
+
+/*
+	FileStateManager keeps the states of the steps in memory and persists them into a JSON file
+	inside the output directory of the workflow, so a later run can pick them up again.
+	Every state is encoded when it is set, the changes are written together at most once per stateFlushInterval
+	and Flush writes what is left once the workflow has finished.
+	All executors writing into the same output directory share the same FileStateManager.
+ */
+type FileStateManager struct {
+	mutex sync.RWMutex
+	path string
+	states map[string]interface{}
+	// encoded holds the JSON of every state as it was when it has been set
+	encoded map[string]json.RawMessage
+	// flushTimer is pending while there are changes which have not been written yet
+	flushTimer *time.Timer
+}
+
+// GetFileStateManager returns the shared state manager of the given output directory
+func GetFileStateManager(outputDir string) (*FileStateManager,error) {
+	path := filepath.Join(outputDir,config2.BIOFLOWS_STATE_FILE)
+	fileStoresMutex.Lock()
+	defer fileStoresMutex.Unlock()
+	if store , ok := fileStores[path]; ok {
+		return store , nil
+	}
+	store := &FileStateManager{path: path}
+	err := store.load()
+	if err != nil {
+		return nil , err
+	}
+	fileStores[path] = store
+	return store , nil
+}
+
+func (c *FileStateManager) load() error {
+	c.states = make(map[string]interface{})
+	c.encoded = make(map[string]json.RawMessage)
+	data , err := ioutil.ReadFile(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	err = json.Unmarshal(data,&c.encoded)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data,&c.states)
+}
+
+// persist writes all states into a temporary file first then renames it, so a crash never leaves a truncated state file
+func (c *FileStateManager) persist() error {
+	data , err := json.Marshal(c.encoded)
+	if err != nil {
+		return err
+	}
+	tempFile := c.path + ".tmp"
+	err = ioutil.WriteFile(tempFile,data,config2.FILE_MODE_WRITABLE_PERM)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFile,c.path)
+}
+
+func (c *FileStateManager) filterKeys(query string) []string {
+	filteredKeys := make([]string,0)
+	for key := range c.states {
+		if strings.HasPrefix(key,query) {
+			filteredKeys = append(filteredKeys,key)
+		}
+	}
+	return filteredKeys
+}
+
+// RemoveConfigByID keeps the states on purpose, they are what a failed workflow is resumed from
+func (c *FileStateManager) RemoveConfigByID(key string) bool {
+	return false
+}
+
+func (c *FileStateManager) GetPipelineState(pipelineKey string) (models.FlowConfig, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	finalConfig := models.FlowConfig{}
+	filteredKeys := c.filterKeys(pipelineKey)
+	if len(filteredKeys) <= 0 {
+		return nil , ERR_NOT_FOUND
+	}
+	for _ , key := range filteredKeys {
+		finalConfig[helpers.GetToolIdFromKey(key)] = c.states[key]
+	}
+	return finalConfig , nil
+}
+
+func (c *FileStateManager) GetStateByID(stepId string) (interface{},error){
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if state , ok := c.states[stepId]; ok {
+		return state , nil
+	}
+	return nil , nil
+}
+
+func (c *FileStateManager) SetStateByID(stepId string,config interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// The state is encoded right away, it could be changed by its step once it has been set
+	data , err := json.Marshal(helpers.ToJsonCompatible(config))
+	if err != nil {
+		return fmt.Errorf("Unable to persist the state of (%s): %s",stepId,err.Error())
+	}
+	c.states[stepId] = config
+	c.encoded[stepId] = data
+	if c.flushTimer == nil {
+		c.flushTimer = time.AfterFunc(stateFlushInterval,func(){
+			if err := c.Flush(); err != nil {
+				fmt.Println(fmt.Sprintf("Error: %s",err.Error()))
+			}
+		})
+	}
+	return nil
+}
+
+// Flush writes the states which have changed since the last write, if any
+func (c *FileStateManager) Flush() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.flushTimer == nil {
+		return nil
+	}
+	c.flushTimer.Stop()
+	c.flushTimer = nil
+	err := c.persist()
+	if err != nil {
+		return fmt.Errorf("Unable to persist the states into (%s): %s",c.path,err.Error())
+	}
+	return nil
+}
+
+func (c *FileStateManager) Setup(config map[string]interface{}) error {
+	if c.states == nil {
+		c.states = make(map[string]interface{})
+	}
+	if c.encoded == nil {
+		c.encoded = make(map[string]json.RawMessage)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	SHELL_SH   = "sh"
+	SHELL_BASH = "bash"
+	SHELL_ZSH  = "zsh"
+	SHELL_NONE = "none"
+)
+
+/*
+	Command is what a tool runs, either a command line run by a shell or, in its exec form,
+	the list of arguments of the process which runs without any shell.
+	In YAML the former is a string and the latter is a list of strings.
+ */
+type Command struct {
+	Line string
+	Args []string
+}
+
+// IsExec tells whether the command is in its exec form
+func (c Command) IsExec() bool {
+	return len(c.Args) > 0
+}
+
+func (c Command) ToString() string {
+	if c.IsExec() {
+		return strings.Join(c.Args," ")
+	}
+	return c.Line
+}
+
+func (c *Command) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var args []string
+	if err := unmarshal(&args); err == nil {
+		c.Line , c.Args = "" , args
+		return nil
+	}
+	var line string
+	if err := unmarshal(&line); err != nil {
+		return err
+	}
+	c.Line , c.Args = line , nil
+	return nil
+}
+
+func (c Command) MarshalYAML() (interface{},error) {
+	if c.IsExec() {
+		return c.Args , nil
+	}
+	return c.Line , nil
+}
+
+func (c *Command) UnmarshalJSON(data []byte) error {
+	var args []string
+	if err := json.Unmarshal(data,&args); err == nil {
+		c.Line , c.Args = "" , args
+		return nil
+	}
+	var line string
+	if err := json.Unmarshal(data,&line); err != nil {
+		return err
+	}
+	c.Line , c.Args = line , nil
+	return nil
+}
+
+func (c Command) MarshalJSON() ([]byte,error) {
+	if c.IsExec() {
+		return json.Marshal(c.Args)
+	}
+	return json.Marshal(c.Line)
+}
+
+// GetShell normalizes the shell directive of a tool, bash is used when it is not set
+func GetShell(shell string) (string,error) {
+	switch strings.ToLower(strings.TrimSpace(shell)) {
+	case "" , SHELL_BASH:
+		return SHELL_BASH , nil
+	case SHELL_SH:
+		return SHELL_SH , nil
+	case SHELL_ZSH:
+		return SHELL_ZSH , nil
+	case SHELL_NONE:
+		return SHELL_NONE , nil
+	default:
+		return "" , fmt.Errorf("unknown shell (%s), it should be one of %s, %s, %s or %s",shell,SHELL_SH,SHELL_BASH,SHELL_ZSH,SHELL_NONE)
+	}
+}
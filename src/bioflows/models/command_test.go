@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v2"
+	"reflect"
+	"testing"
+)
+
+func TestCommandUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		contents string
+		expected Command
+		exec     bool
+	}{
+		{contents: "command: samtools sort -o out.bam in.bam",expected: Command{Line: "samtools sort -o out.bam in.bam"}},
+		{contents: "command: |\n  echo one\n  echo two\n",expected: Command{Line: "echo one\necho two\n"}},
+		{contents: "command: [\"samtools\",\"sort\",\"in.bam\"]",expected: Command{Args: []string{"samtools","sort","in.bam"}},exec: true},
+		{contents: "command:\n  - bwa\n  - mem\n",expected: Command{Args: []string{"bwa","mem"}},exec: true},
+	}
+	for _ , test := range tests {
+		var tool struct {
+			Command Command `yaml:"command"`
+		}
+		if err := yaml.Unmarshal([]byte(test.contents),&tool); err != nil {
+			t.Errorf("(%s): %s",test.contents,err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(tool.Command,test.expected) || tool.Command.IsExec() != test.exec {
+			t.Errorf("(%s): expected %#v, got %#v",test.contents,test.expected,tool.Command)
+		}
+	}
+	var tool struct {
+		Command Command `yaml:"command"`
+	}
+	if err := yaml.Unmarshal([]byte("command:\n  run: bwa\n"),&tool); err == nil {
+		t.Error("expected an error for a command which is neither a string nor a list")
+	}
+}
+
+func TestCommandRoundTrip(t *testing.T) {
+	for _ , command := range []Command{{Line: "bwa mem ref.fa reads.fq"},{Args: []string{"bwa","mem","ref.fa"}}} {
+		contents , err := yaml.Marshal(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromYAML Command
+		if err = yaml.Unmarshal(contents,&fromYAML); err != nil || !reflect.DeepEqual(fromYAML,command) {
+			t.Errorf("YAML: expected %#v, got %#v (%v)",command,fromYAML,err)
+		}
+		contents , err = json.Marshal(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromJSON Command
+		if err = json.Unmarshal(contents,&fromJSON); err != nil || !reflect.DeepEqual(fromJSON,command) {
+			t.Errorf("JSON: expected %#v, got %#v (%v)",command,fromJSON,err)
+		}
+	}
+}
@@ -6,6 +6,7 @@ type SystemEmail struct{
 	Port int `json:"port,omitempty" yaml:"port,omitempty"`
 	Username string `json:"username,omitempty" yaml:"username,omitempty"`
 	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	From string `json:"from,omitempty" yaml:"from,omitempty"`
 	SSL bool `json:"ssl,omitempty" yaml:"ssl,omitempty"`
 	TLS bool `json:"tls,omitempty" yaml:"tls,omitempty"`
 }
@@ -16,6 +17,7 @@ func (e SystemEmail) ToMap() map[string]interface{}{
 	m["port"] = e.Port
 	m["username"] = e.Username
 	m["password"] = e.Password
+	m["from"] = e.From
 	m["ssl"] = e.SSL
 	m["tls"] = e.TLS
 	return m
@@ -35,10 +37,29 @@ func (c SystemCluster) ToMap() map[string]interface{}{
 	return m
 }
 
+type SystemResources struct {
+	CPU int `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+	Memory int `json:"memory,omitempty" yaml:"memory,omitempty"`
+	MaxParallel int `json:"max_parallel,omitempty" yaml:"max_parallel,omitempty"`
+}
+
+func (r SystemResources) ToMap() map[string]interface{}{
+	m := make(map[string]interface{})
+	m["cpu"] = r.CPU
+	m["memory"] = r.Memory
+	m["max_parallel"] = r.MaxParallel
+	return m
+}
+
 type SystemConfig struct {
 	Remote bool `json:"remote,omitempty" yaml:"remote,omitempty"`
 	Email SystemEmail `json:"email,omitempty" yaml:"email,omitempty"`
 	Cluster SystemCluster `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	Resources SystemResources `json:"resources,omitempty" yaml:"resources,omitempty"`
+	StepDirLayout string `json:"step_dir_layout,omitempty" yaml:"step_dir_layout,omitempty"`
+	ScratchRoot string `json:"scratch_root,omitempty" yaml:"scratch_root,omitempty"`
+	PublishDir string `json:"publish_dir,omitempty" yaml:"publish_dir,omitempty"`
+	PullPolicy string `json:"pull_policy,omitempty" yaml:"pull_policy,omitempty"`
 }
 
 func (c SystemConfig) ToMap() map[string]interface{}{
@@ -46,5 +67,19 @@ func (c SystemConfig) ToMap() map[string]interface{}{
 	m["remote"] = c.Remote
 	m["email"] = c.Email.ToMap()
 	m["cluster"] = c.Cluster.ToMap()
+	m["resources"] = c.Resources.ToMap()
+	// Directory settings are only set when configured, so they fall back to their defaults
+	if len(c.StepDirLayout) > 0 {
+		m["step_dir_layout"] = c.StepDirLayout
+	}
+	if len(c.ScratchRoot) > 0 {
+		m["scratch_root"] = c.ScratchRoot
+	}
+	if len(c.PublishDir) > 0 {
+		m["publish_dir"] = c.PublishDir
+	}
+	if len(c.PullPolicy) > 0 {
+		m["pull_policy"] = c.PullPolicy
+	}
 	return m
 }
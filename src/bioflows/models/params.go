@@ -0,0 +1,262 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	PARAM_TYPE_STRING    = "string"
+	PARAM_TYPE_INT       = "int"
+	PARAM_TYPE_FLOAT     = "float"
+	PARAM_TYPE_BOOL      = "bool"
+	PARAM_TYPE_FILE      = "file"
+	PARAM_TYPE_DIR       = "dir"
+	PARAM_TYPE_DIRECTORY = "directory"
+	PARAM_TYPE_ARRAY     = "array"
+	PARAM_TYPE_ENUM      = "enum"
+	PARAM_TYPE_GLOB      = "glob"
+)
+
+var paramTypes = []string{PARAM_TYPE_STRING,PARAM_TYPE_INT,PARAM_TYPE_FLOAT,PARAM_TYPE_BOOL,PARAM_TYPE_FILE,
+	PARAM_TYPE_DIR,PARAM_TYPE_DIRECTORY,PARAM_TYPE_ARRAY,PARAM_TYPE_ENUM,PARAM_TYPE_GLOB}
+
+// ParameterErrors collects all the invalid or missing parameters found before running a step
+type ParameterErrors []error
+
+func (e ParameterErrors) Error() string {
+	messages := make([]string,len(e))
+	for idx , err := range e {
+		messages[idx] = err.Error()
+	}
+	return fmt.Sprintf("Invalid parameters, %d error(s) found:\n%s",len(e),strings.Join(messages,"\n"))
+}
+
+// GetType returns the normalized type of the parameter, an empty type means the parameter is not typed
+func (p *Parameter) GetType() string {
+	return strings.ToLower(strings.TrimSpace(p.Type))
+}
+
+// IsKnownType tells whether the parameter is untyped or has one of the types BioFlows enforces
+func (p *Parameter) IsKnownType() bool {
+	paramType := p.GetType()
+	known := len(paramType) == 0
+	for _ , t := range paramTypes {
+		known = known || paramType == t
+	}
+	return known
+}
+
+// TypeWarning explains how a parameter with a type BioFlows does not know is handled, it is empty for the known types
+func (p *Parameter) TypeWarning() string {
+	if p.IsKnownType() {
+		return ""
+	}
+	return fmt.Sprintf("parameter (%s) has unknown type (%s), it is kept as an untyped parameter, the known types are %s",
+		p.Name,p.Type,strings.Join(paramTypes,", "))
+}
+
+/*
+	Validate checks the declaration of the parameter itself, regardless of its value.
+	Types were free-form before they have been enforced, so a type which is not known is not an error,
+	such a parameter keeps its value as it is like an untyped one.
+ */
+func (p *Parameter) Validate() error {
+	paramType := p.GetType()
+	if paramType == PARAM_TYPE_ENUM && len(p.Choices) == 0 {
+		return fmt.Errorf("parameter (%s) is an enum but it has no choices",p.Name)
+	}
+	if len(p.Pattern) > 0 {
+		if _ , err := regexp.Compile(p.Pattern); err != nil {
+			return fmt.Errorf("parameter (%s) has an invalid pattern (%s): %s",p.Name,p.Pattern,err.Error())
+		}
+	}
+	if p.Min != nil && p.Max != nil && *p.Min > *p.Max {
+		return fmt.Errorf("parameter (%s) has min (%v) greater than max (%v)",p.Name,*p.Min,*p.Max)
+	}
+	return nil
+}
+
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if str , ok := value.(string); ok {
+		return len(strings.TrimSpace(str)) == 0
+	}
+	return false
+}
+
+/*
+	Resolve returns the value of the parameter coerced to its type, the default value is used when the given value is empty.
+	It fails when a required parameter has no value or the value does not satisfy the constraints of the parameter.
+	A nil value is returned for an optional parameter without a value.
+ */
+func (p *Parameter) Resolve(value interface{}) (interface{},error) {
+	if err := p.Validate(); err != nil {
+		return nil , err
+	}
+	if isEmptyValue(value) {
+		value = p.Default
+	}
+	if isEmptyValue(value) {
+		if p.Required {
+			return nil , fmt.Errorf("parameter (%s) is required but it has no value",p.Name)
+		}
+		return nil , nil
+	}
+	coerced , err := p.Coerce(value)
+	if err != nil {
+		return nil , err
+	}
+	return coerced , p.checkConstraints(coerced)
+}
+
+// Coerce converts the given value into the type of the parameter, untyped parameters keep their values as they are
+func (p *Parameter) Coerce(value interface{}) (interface{},error) {
+	switch p.GetType() {
+	case PARAM_TYPE_STRING , PARAM_TYPE_FILE , PARAM_TYPE_DIR , PARAM_TYPE_DIRECTORY , PARAM_TYPE_ENUM , PARAM_TYPE_GLOB:
+		return fmt.Sprintf("%v",value) , nil
+	case PARAM_TYPE_INT:
+		switch v := value.(type) {
+		case int:
+			return v , nil
+		case int64:
+			return int(v) , nil
+		case float64:
+			if v == math.Trunc(v) {
+				return int(v) , nil
+			}
+		case string:
+			if converted , err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return converted , nil
+			}
+		}
+		return nil , fmt.Errorf("parameter (%s) should be an int, got (%v)",p.Name,value)
+	case PARAM_TYPE_FLOAT:
+		switch v := value.(type) {
+		case int:
+			return float64(v) , nil
+		case int64:
+			return float64(v) , nil
+		case float64:
+			return v , nil
+		case string:
+			if converted , err := strconv.ParseFloat(strings.TrimSpace(v),64); err == nil {
+				return converted , nil
+			}
+		}
+		return nil , fmt.Errorf("parameter (%s) should be a float, got (%v)",p.Name,value)
+	case PARAM_TYPE_BOOL:
+		switch v := value.(type) {
+		case bool:
+			return v , nil
+		case string:
+			if converted , err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return converted , nil
+			}
+		}
+		return nil , fmt.Errorf("parameter (%s) should be a bool, got (%v)",p.Name,value)
+	case PARAM_TYPE_ARRAY:
+		switch v := value.(type) {
+		case []interface{}:
+			return v , nil
+		case []string:
+			elements := make([]interface{},len(v))
+			for idx , element := range v {
+				elements[idx] = element
+			}
+			return elements , nil
+		case string:
+			// Arrays passed on the command line are either JSON arrays or comma separated values
+			v = strings.TrimSpace(v)
+			elements := make([]interface{},0)
+			if strings.HasPrefix(v,"[") {
+				if err := json.Unmarshal([]byte(v),&elements); err != nil {
+					return nil , fmt.Errorf("parameter (%s) should be an array, got (%v): %s",p.Name,value,err.Error())
+				}
+				return elements , nil
+			}
+			for _ , element := range strings.Split(v,",") {
+				elements = append(elements,strings.TrimSpace(element))
+			}
+			return elements , nil
+		}
+		return nil , fmt.Errorf("parameter (%s) should be an array, got (%v)",p.Name,value)
+	default:
+		return value , nil
+	}
+}
+
+func (p *Parameter) checkConstraints(value interface{}) error {
+	values := []interface{}{value}
+	if elements , ok := value.([]interface{}); ok {
+		values = elements
+	}
+	if len(p.Choices) > 0 {
+		for _ , v := range values {
+			allowed := false
+			for _ , choice := range p.Choices {
+				allowed = allowed || fmt.Sprintf("%v",choice) == fmt.Sprintf("%v",v)
+			}
+			if !allowed {
+				return fmt.Errorf("parameter (%s) should be one of %v, got (%v)",p.Name,p.Choices,v)
+			}
+		}
+	}
+	if len(p.Pattern) > 0 {
+		pattern := regexp.MustCompile(p.Pattern)
+		for _ , v := range values {
+			if !pattern.MatchString(fmt.Sprintf("%v",v)) {
+				return fmt.Errorf("parameter (%s) should match the pattern (%s), got (%v)",p.Name,p.Pattern,v)
+			}
+		}
+	}
+	// min and max bound numbers, or the number of elements of arrays
+	var measure float64
+	switch v := value.(type) {
+	case int:
+		measure = float64(v)
+	case float64:
+		measure = v
+	case []interface{}:
+		measure = float64(len(v))
+	default:
+		return nil
+	}
+	if p.Min != nil && measure < *p.Min {
+		return fmt.Errorf("parameter (%s) should be at least %v, got (%v)",p.Name,*p.Min,measure)
+	}
+	if p.Max != nil && measure > *p.Max {
+		return fmt.Errorf("parameter (%s) should be at most %v, got (%v)",p.Name,*p.Max,measure)
+	}
+	return nil
+}
+
+// CheckPath makes sure that the value of a file or a dir parameter exists locally, remote locations are not checked
+func (p *Parameter) CheckPath(value interface{}) error {
+	paramType := p.GetType()
+	if value == nil || (paramType != PARAM_TYPE_FILE && paramType != PARAM_TYPE_DIR && paramType != PARAM_TYPE_DIRECTORY) {
+		return nil
+	}
+	location := fmt.Sprintf("%v",value)
+	if strings.Contains(location,"://") {
+		return nil
+	}
+	info , err := os.Stat(location)
+	if err != nil {
+		return fmt.Errorf("parameter (%s) refers to a %s which does not exist (%s)",p.Name,paramType,location)
+	}
+	if paramType == PARAM_TYPE_FILE && info.IsDir() {
+		return fmt.Errorf("parameter (%s) should be a file but (%s) is a directory",p.Name,location)
+	}
+	if paramType != PARAM_TYPE_FILE && !info.IsDir() {
+		return fmt.Errorf("parameter (%s) should be a directory but (%s) is not",p.Name,location)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParameterCoerce(t *testing.T) {
+	tests := []struct {
+		paramType string
+		value     interface{}
+		expected  interface{}
+		fails     bool
+	}{
+		{paramType: PARAM_TYPE_STRING,value: 12,expected: "12"},
+		{paramType: PARAM_TYPE_FILE,value: "reads.fastq",expected: "reads.fastq"},
+		{paramType: PARAM_TYPE_INT,value: "42",expected: 42},
+		{paramType: PARAM_TYPE_INT,value: float64(3),expected: 3},
+		{paramType: PARAM_TYPE_INT,value: 3.5,fails: true},
+		{paramType: PARAM_TYPE_INT,value: "many",fails: true},
+		{paramType: PARAM_TYPE_FLOAT,value: 2,expected: float64(2)},
+		{paramType: PARAM_TYPE_FLOAT,value: " 0.05 ",expected: 0.05},
+		{paramType: PARAM_TYPE_FLOAT,value: true,fails: true},
+		{paramType: PARAM_TYPE_BOOL,value: "true",expected: true},
+		{paramType: PARAM_TYPE_BOOL,value: 1,fails: true},
+		{paramType: PARAM_TYPE_ARRAY,value: "a, b,c",expected: []interface{}{"a","b","c"}},
+		{paramType: PARAM_TYPE_ARRAY,value: `["a",1]`,expected: []interface{}{"a",float64(1)}},
+		{paramType: PARAM_TYPE_ARRAY,value: []string{"a","b"},expected: []interface{}{"a","b"}},
+		{paramType: PARAM_TYPE_ARRAY,value: 5,fails: true},
+		{paramType: "",value: 5,expected: 5},
+		{paramType: "custom",value: 5,expected: 5},
+	}
+	for _ , test := range tests {
+		param := &Parameter{Name: "param",Type: test.paramType}
+		actual , err := param.Coerce(test.value)
+		if test.fails {
+			if err == nil {
+				t.Errorf("%s (%v): expected an error, got (%v)",test.paramType,test.value,actual)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s (%v): unexpected error: %s",test.paramType,test.value,err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(actual,test.expected) {
+			t.Errorf("%s (%v): expected (%#v), got (%#v)",test.paramType,test.value,test.expected,actual)
+		}
+	}
+}
@@ -32,6 +32,50 @@ func Clone(o *BioPipeline, t *BioPipeline, config models.FlowConfig) error {
 	o.URL = t.URL
 	o.ImageId = t.ImageId
 	o.Caps = t.Caps
+	if o.Retry == nil {
+		o.Retry = t.Retry
+	}
+	if len(o.Timeout) == 0 {
+		o.Timeout = t.Timeout
+	}
+	if o.Cache == nil {
+		o.Cache = t.Cache
+	}
+	// Optional flags are inherited unless the step sets them, an explicit false wins
+	if o.AcceptSkipped == nil {
+		o.AcceptSkipped = t.AcceptSkipped
+	}
+	if o.AllowFailure == nil {
+		o.AllowFailure = t.AllowFailure
+	}
+	if o.Parallelism == 0 {
+		o.Parallelism = t.Parallelism
+	}
+	o.Env = models.MergeEnv(t.Env,o.Env)
+	if len(o.Stdout) == 0 {
+		o.Stdout = t.Stdout
+	}
+	if len(o.Shell) == 0 {
+		o.Shell = t.Shell
+	}
+	if o.Tee == nil {
+		o.Tee = t.Tee
+	}
+	if o.Scratch == nil {
+		o.Scratch = t.Scratch
+	}
+	if o.KeepScratch == nil {
+		o.KeepScratch = t.KeepScratch
+	}
+	if o.Publish == nil {
+		o.Publish = t.Publish
+	}
+	if len(o.PullPolicy) == 0 {
+		o.PullPolicy = t.PullPolicy
+	}
+	if len(o.OnFailure) == 0 {
+		o.OnFailure = t.OnFailure
+	}
 	o.Type = t.Type
 	o.BioflowId = t.BioflowId
 	if len(o.Name) <= 0{
@@ -124,8 +168,10 @@ func Clone(o *BioPipeline, t *BioPipeline, config models.FlowConfig) error {
 	o.Dependencies = make([]string,len(t.Dependencies))
 	copy(o.Dependencies,t.Dependencies)
 	o.Deprecated = t.Deprecated
-	o.Conditions = make([]models.Scriptable,len(t.Conditions))
-	copy(o.Conditions,t.Conditions)
+	// The conditions of the step are kept and the conditions of the tool are added to them
+	conditions := make([]models.Scriptable,0,len(o.Conditions) + len(t.Conditions))
+	conditions = append(conditions,o.Conditions...)
+	o.Conditions = append(conditions,t.Conditions...)
 
 	o.Steps = make([]BioPipeline,len(t.Steps))
 	copy(o.Steps,t.Steps)
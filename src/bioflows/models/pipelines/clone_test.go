@@ -0,0 +1,41 @@
+package pipelines
+
+import (
+	"bioflows/models"
+	"testing"
+)
+
+func flag(value bool) *bool {
+	return &value
+}
+
+func TestCloneFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		step     *bool
+		tool     *bool
+		expected bool
+	}{
+		{name: "inherited",step: nil,tool: flag(true),expected: true},
+		{name: "explicit false",step: flag(false),tool: flag(true),expected: false},
+		{name: "explicit true",step: flag(true),tool: flag(false),expected: true},
+		{name: "unset",step: nil,tool: nil,expected: false},
+	}
+	for _ , test := range tests {
+		step := &BioPipeline{ID: "step",AcceptSkipped: test.step,AllowFailure: test.step,Tee: test.step,Scratch: test.step,KeepScratch: test.step}
+		tool := &BioPipeline{ID: "tool",AcceptSkipped: test.tool,AllowFailure: test.tool,Tee: test.tool,Scratch: test.tool,KeepScratch: test.tool}
+		if err := Clone(step,tool,models.FlowConfig{}); err != nil {
+			t.Fatal(err)
+		}
+		if step.AcceptsSkipped() != test.expected {
+			t.Errorf("%s: expected accept_skipped to be %v",test.name,test.expected)
+		}
+		if step.AllowsFailure() != test.expected {
+			t.Errorf("%s: expected allow_failure to be %v",test.name,test.expected)
+		}
+		cloned := step.ToTool()
+		if cloned.Tee != test.expected || cloned.Scratch != test.expected || cloned.KeepScratch != test.expected {
+			t.Errorf("%s: expected tee, scratch and keep_scratch to be %v",test.name,test.expected)
+		}
+	}
+}
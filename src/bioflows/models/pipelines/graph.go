@@ -6,7 +6,6 @@ import (
 	viz "github.com/goccy/go-graphviz"
 	"github.com/goccy/go-graphviz/cgraph"
 	"github.com/goombaio/dag"
-	"strings"
 )
 
 
@@ -23,32 +22,60 @@ func GraphContains(g *dag.DAG, v string) bool {
 	return err == nil
 }
 
+/*
+	CreateGraph builds the DAG of the steps of the given pipeline in two passes, all steps are collected first
+	so they could depend on steps defined after them, then the edges are wired. Edges are also inferred from references
+	to the outputs of other steps. Duplicate step IDs, unknown dependencies, unknown references, cycles and invalid parameter declarations are all reported together as GraphErrors.
+ */
 func CreateGraph(b *BioPipeline) (*dag.DAG,error){
-	g := dag.NewDAG()
-	processedSteps := make(map[string]*dag.Vertex)
+	errs := validateParameters(*b)
+	steps := make([]BioPipeline,0,len(b.Steps))
+	index := make(map[string]int)
+	// First pass: collect every step
 	for _ , step := range b.Steps {
 		step.Prepare()
-		if len(step.Depends) <= 0{
-			vertex := dag.NewVertex(step.ID,step)
-			g.AddVertex(vertex)
-			processedSteps[step.ID] = vertex
-		}else{
-			from := step.Depends
-			fromNodes := strings.Split(from,",")
-			for _ , fromNode := range fromNodes{
-				currentVertex := dag.NewVertex(step.ID,step)
-				if GraphContains(g,step.ID){
-					currentVertex , _ = g.GetVertex(step.ID)
-				}
-				if parentVertex, ok := processedSteps[fromNode]; !ok {
-					panic(fmt.Errorf("Unknown Bioflows Step mentioned in %s",step.Name))
-				}else{
-					g.AddVertex(currentVertex)
-					g.AddEdge(parentVertex,currentVertex)
-
-					processedSteps[step.ID] = currentVertex
-				}
+		if previous , ok := index[step.ID]; ok {
+			errs = append(errs,GraphError{Kind: GRAPH_ERROR_DUPLICATE_STEP,Step: step.ID,Line: step.Line,PreviousLine: steps[previous].Line})
+			continue
+		}
+		index[step.ID] = len(steps)
+		steps = append(steps,step)
+		errs = append(errs,validateParameters(step)...)
+	}
+	for _ , step := range steps {
+		for _ , dependency := range step.GetDeclaredDepends() {
+			if _ , ok := index[dependency]; !ok {
+				errs = append(errs,GraphError{Kind: GRAPH_ERROR_UNKNOWN_DEPENDENCY,Step: step.ID,Line: step.Line,Dependency: dependency})
+			}
+		}
+		for _ , reference := range step.GetStepReferences() {
+			referenced , ok := index[reference.Step]
+			if !ok {
+				errs = append(errs,GraphError{Kind: GRAPH_ERROR_UNKNOWN_REFERENCE,Step: step.ID,Line: step.Line,Dependency: reference.Step,Output: reference.Output})
+				continue
 			}
+			// The outputs of a step which is downloaded from a URL are only known once it is fetched
+			target := steps[referenced]
+			if !target.DeclaresOutput(reference.Output) && (len(target.URL) == 0 || len(target.Outputs) > 0) {
+				errs = append(errs,GraphError{Kind: GRAPH_ERROR_UNKNOWN_OUTPUT,Step: step.ID,Line: step.Line,Dependency: reference.Step,Output: reference.Output})
+			}
+		}
+	}
+	errs = append(errs,findCycles(steps,index)...)
+	if len(errs) > 0 {
+		return nil , errs
+	}
+	// Second pass: wire the edges
+	g := dag.NewDAG()
+	vertices := make(map[string]*dag.Vertex)
+	for _ , step := range steps {
+		vertex := dag.NewVertex(step.ID,step)
+		g.AddVertex(vertex)
+		vertices[step.ID] = vertex
+	}
+	for _ , step := range steps {
+		for _ , dependency := range step.GetDepends() {
+			g.AddEdge(vertices[dependency],vertices[step.ID])
 		}
 	}
 	return g, nil
@@ -0,0 +1,45 @@
+package pipelines
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+/*
+	LocateLines records the line number of every step in the given YAML contents of the pipeline.
+	yaml.v2 does not keep the positions of the decoded nodes, so the contents are parsed again into yaml.v3 nodes
+	and the items of every "steps" sequence are matched with the decoded steps in the same order.
+	A step is located at its "id" key, or at the beginning of its item when it has none.
+ */
+func LocateLines(b *BioPipeline , contents []byte) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(contents,&document); err != nil || len(document.Content) == 0 {
+		return
+	}
+	assignLines(b,document.Content[0])
+}
+
+func assignLines(b *BioPipeline , node *yaml.Node) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	b.Line = node.Line
+	for idx := 0 ; idx + 1 < len(node.Content) ; idx += 2 {
+		key , value := node.Content[idx] , node.Content[idx+1]
+		switch key.Value {
+		case "id":
+			b.Line = key.Line
+		case "steps":
+			if value.Kind != yaml.SequenceNode {
+				continue
+			}
+			for stepIdx , item := range value.Content {
+				if stepIdx < len(b.Steps) {
+					assignLines(&b.Steps[stepIdx],item)
+				}
+			}
+		}
+	}
+}
@@ -5,6 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
+)
+
+const (
+	FAILURE_POLICY_CONTINUE       = "continue"
+	FAILURE_POLICY_FAIL_FAST      = "fail_fast"
+	FAILURE_POLICY_FINISH_RUNNING = "finish_running"
 )
 
 type BioPipeline struct {
@@ -24,20 +31,39 @@ type BioPipeline struct {
 	Shadow       bool                 `json:"shadow,omitempty" yaml:"shadow,omitempty"`
 	Loop bool 	`json:"loop,omitempty" yaml:"loop,omitempty"`
 	LoopVar string `json:"loop_var,omitempty" yaml:"loop_var,omitempty"`
+	Scatter []string `json:"scatter,omitempty" yaml:"scatter,omitempty"`
+	ScatterMode string `json:"scatter_mode,omitempty" yaml:"scatter_mode,omitempty"`
+	Parallelism int `json:"parallelism,omitempty" yaml:"parallelism,omitempty"`
 	Maintainer   *models.Maintainer   `json:"maintainer,omitempty" yaml:"maintainer,omitempty"`
 	References   []models.Reference   `json:"references,omitempty" yaml:"references,omitempty"`
 	Inputs       []models.Parameter   `json:"inputs,omitempty" yaml:"inputs,omitempty"`
 	Config       []models.Parameter   `json:"config,omitempty" yaml:"config,omitempty"`
 	Outputs      []models.Parameter   `json:"outputs,omitempty" yaml:"outputs,omitempty"`
 	Scripts      []models.Script      `json:"scripts,omitempty" yaml:"scripts,omitempty"`
-	Command      models.Scriptable    `json:"command" yaml:"command"`
+	Command      models.Command       `json:"command" yaml:"command"`
+	Shell        string               `json:"shell,omitempty" yaml:"shell,omitempty"`
 	Dependencies []string             `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
 	Deprecated   bool                 `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
 	Conditions   []models.Scriptable  `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	AcceptSkipped *bool               `json:"accept_skipped,omitempty" yaml:"accept_skipped,omitempty"`
+	AllowFailure *bool                `json:"allow_failure,omitempty" yaml:"allow_failure,omitempty"`
+	OnFailure    string               `json:"on_failure,omitempty" yaml:"on_failure,omitempty"`
+	// Line is where the step is defined in its YAML file, it is only used to report errors
+	Line         int                  `json:"-" yaml:"-"`
 	Steps        []BioPipeline        `json:"steps,omitempty" yaml:"steps,omitempty"`
 	Notification *models.Notification `json:"notification,omitempty" yaml:"notification,omitempty"`
 	Caps         *models.Capabilities `json:"caps,omitempty" yaml:"caps,omitempty"`
+	Retry        *models.RetryPolicy  `json:"retry,omitempty" yaml:"retry,omitempty"`
+	Timeout      string               `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Cache        *bool                `json:"cache,omitempty" yaml:"cache,omitempty"`
+	Env          map[string]string    `json:"env,omitempty" yaml:"env,omitempty"`
+	Stdout       string               `json:"stdout,omitempty" yaml:"stdout,omitempty"`
+	Tee          *bool                `json:"tee,omitempty" yaml:"tee,omitempty"`
+	Scratch      *bool                `json:"scratch,omitempty" yaml:"scratch,omitempty"`
+	KeepScratch  *bool                `json:"keep_scratch,omitempty" yaml:"keep_scratch,omitempty"`
+	Publish      *models.Publish      `json:"publish,omitempty" yaml:"publish,omitempty"`
 	ContainerConfig *models.ContainerConfig `json:"container,omitempty" yaml:"container,omitempty"`
+	PullPolicy   string               `json:"pull_policy,omitempty" yaml:"pull_policy,omitempty"`
 }
 
 func (instance *BioPipeline) GetIdentifier() string {
@@ -57,6 +83,52 @@ func (instance *BioPipeline) Prepare() {
 	instance.Name = instance.Name
 }
 
+// isSet returns the value of an optional flag, false when it is not set
+func isSet(flag *bool) bool {
+	return flag != nil && *flag
+}
+
+// AcceptsSkipped tells whether the step runs even though some of its dependencies have been skipped
+func (instance BioPipeline) AcceptsSkipped() bool {
+	return isSet(instance.AcceptSkipped)
+}
+
+// AllowsFailure tells whether the failure of the step does not block the steps depending on it
+func (instance BioPipeline) AllowsFailure() bool {
+	return isSet(instance.AllowFailure)
+}
+
+// GetDeclaredDepends returns the unique IDs of the steps listed in the depends directive of the current step
+func (instance BioPipeline) GetDeclaredDepends() []string {
+	depends := make([]string,0)
+	seen := make(map[string]bool)
+	for _ , dependency := range strings.Split(instance.Depends,",") {
+		dependency = strings.TrimSpace(dependency)
+		if len(dependency) > 0 && !seen[dependency] {
+			seen[dependency] = true
+			depends = append(depends,dependency)
+		}
+	}
+	return depends
+}
+
+// GetDepends returns the unique IDs of the steps which the current step depends on, either declared
+// in the depends directive or inferred from the references to their outputs
+func (instance BioPipeline) GetDepends() []string {
+	depends := instance.GetDeclaredDepends()
+	seen := make(map[string]bool)
+	for _ , dependency := range depends {
+		seen[dependency] = true
+	}
+	for _ , reference := range instance.GetStepReferences() {
+		if !seen[reference.Step] {
+			seen[reference.Step] = true
+			depends = append(depends,reference.Step)
+		}
+	}
+	return depends
+}
+
 func (instance BioPipeline) GetInLoopScripts() []models.Script {
 	scripts := make([]models.Script,1)
 	for _ , script := range instance.Scripts {
@@ -78,6 +150,16 @@ func (p BioPipeline) ToTool() *models.Tool {
 	t.ID = p.ID
 	t.Order = p.Order
 	t.Caps = p.Caps
+	t.Retry = p.Retry
+	t.Timeout = p.Timeout
+	t.Cache = p.Cache
+	t.Parallelism = p.Parallelism
+	t.Env = models.MergeEnv(nil,p.Env)
+	t.Stdout = p.Stdout
+	t.Tee = isSet(p.Tee)
+	t.Scratch = isSet(p.Scratch)
+	t.KeepScratch = isSet(p.KeepScratch)
+	t.Publish = p.Publish
 	t.BioflowId = p.BioflowId
 	t.Name = p.BioflowId
 	t.Description = p.Description
@@ -90,6 +172,9 @@ func (p BioPipeline) ToTool() *models.Tool {
 	t.Maintainer = p.Maintainer
 	t.Loop = p.Loop
 	t.LoopVar = p.LoopVar
+	t.Scatter = make([]string,len(p.Scatter))
+	copy(t.Scatter,p.Scatter)
+	t.ScatterMode = p.ScatterMode
 	t.Scripts = make([]models.Script,len(p.Scripts))
 	copy(t.Scripts,p.Scripts)
 	t.References = make([]models.Reference, len(p.References))
@@ -101,15 +186,40 @@ func (p BioPipeline) ToTool() *models.Tool {
 	t.Outputs = make([]models.Parameter, len(p.Outputs))
 	copy(t.Outputs, p.Outputs)
 	t.Command = p.Command
+	t.Shell = p.Shell
 	t.Dependencies = make([]string, len(p.Dependencies))
 	copy(t.Dependencies, p.Dependencies)
 	t.Deprecated = p.Deprecated
 	t.Conditions = make([]models.Scriptable, len(p.Conditions))
 	copy(t.Conditions,p.Conditions)
 	t.ContainerConfig = p.ContainerConfig
+	t.PullPolicy = p.PullPolicy
 	return t
 }
 
+// GetTimeout parses the timeout directive of the step or pipeline (e.g. 90m, 2h30m), zero means no timeout
+func (p BioPipeline) GetTimeout() (time.Duration,error) {
+	if len(p.Timeout) == 0 {
+		return 0 , nil
+	}
+	return time.ParseDuration(p.Timeout)
+}
+
+// GetFailurePolicy returns what the pipeline does once one of its steps fails, defaults to continue
+func (p BioPipeline) GetFailurePolicy() (string,error) {
+	switch strings.ToLower(p.OnFailure) {
+	case "" , FAILURE_POLICY_CONTINUE:
+		return FAILURE_POLICY_CONTINUE , nil
+	case FAILURE_POLICY_FAIL_FAST:
+		return FAILURE_POLICY_FAIL_FAST , nil
+	case FAILURE_POLICY_FINISH_RUNNING:
+		return FAILURE_POLICY_FINISH_RUNNING , nil
+	default:
+		return "" , fmt.Errorf("unknown failure policy (%s), it should be one of %s, %s or %s",p.OnFailure,
+			FAILURE_POLICY_FAIL_FAST,FAILURE_POLICY_CONTINUE,FAILURE_POLICY_FINISH_RUNNING)
+	}
+}
+
 func (p BioPipeline) IsTool() bool {
 	if len(p.Type) <= 0 {
 		return true
@@ -123,8 +233,13 @@ func (p BioPipeline) IsTool() bool {
 func (p BioPipeline) IsPipeline() bool {
 	return !p.IsTool()
 }
+// GetLoopVars returns the variables the step loops over
+func (p BioPipeline) GetLoopVars() []string {
+	return models.GetLoopVars(p.LoopVar,p.Scatter)
+}
+
 func (p BioPipeline) IsLoop() bool {
-	return p.Loop
+	return p.Loop || len(p.Scatter) > 0
 }
 
 func (p *BioPipeline) ToJson() string {
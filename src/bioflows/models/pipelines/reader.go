@@ -13,9 +13,19 @@ func ReadPipeline(file io.Reader) (*BioPipeline,error) {
 		return nil , err
 	}
 	p := &BioPipeline{}
-	err = yaml.Unmarshal([]byte(contents),p)
+	err = ParsePipeline(contents,p)
 	if err != nil {
 		return nil , err
 	}
 	return p , nil
 }
+
+// ParsePipeline decodes the YAML contents of a pipeline and records the line number of each of its steps
+func ParsePipeline(contents []byte , p *BioPipeline) error {
+	err := yaml.Unmarshal(contents,p)
+	if err != nil {
+		return err
+	}
+	LocateLines(p,contents)
+	return nil
+}
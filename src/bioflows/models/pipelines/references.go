@@ -0,0 +1,62 @@
+package pipelines
+
+import (
+	"bioflows/models"
+	"regexp"
+)
+
+var (
+	mustacheTagPattern = regexp.MustCompile(`\{\{\{?([^}]*)\}?\}\}`)
+	stepReferencePattern = regexp.MustCompile(`steps\.([\w\-]+)\.outputs\.([\w\-]+)`)
+)
+
+// StepReference is an explicit reference to an output of another step, e.g. {{steps.align.outputs.bam}}
+type StepReference struct {
+	Step string
+	Output string
+}
+
+// findStepReferences returns the step output references found in the mustache tags of the given text
+func findStepReferences(text string) []StepReference {
+	references := make([]StepReference,0)
+	for _ , tag := range mustacheTagPattern.FindAllStringSubmatch(text,-1) {
+		for _ , match := range stepReferencePattern.FindAllStringSubmatch(tag[1],-1) {
+			references = append(references,StepReference{Step: match[1],Output: match[2]})
+		}
+	}
+	return references
+}
+
+// GetStepReferences returns the unique references to outputs of other steps in the command and the parameters of the current step
+func (instance BioPipeline) GetStepReferences() []StepReference {
+	texts := []string{instance.Command.ToString()}
+	for _ , params := range [][]models.Parameter{instance.Inputs,instance.Config,instance.Outputs} {
+		for _ , param := range params {
+			if param.Value == nil {
+				continue
+			}
+			texts = append(texts,param.GetParamValue())
+		}
+	}
+	references := make([]StepReference,0)
+	seen := make(map[StepReference]bool)
+	for _ , text := range texts {
+		for _ , reference := range findStepReferences(text) {
+			if !seen[reference] {
+				seen[reference] = true
+				references = append(references,reference)
+			}
+		}
+	}
+	return references
+}
+
+// DeclaresOutput tells whether the current step declares an output with the given name
+func (instance BioPipeline) DeclaresOutput(name string) bool {
+	for _ , param := range instance.Outputs {
+		if param.Name == name {
+			return true
+		}
+	}
+	return false
+}
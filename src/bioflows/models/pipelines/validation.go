@@ -0,0 +1,172 @@
+package pipelines
+
+import (
+	"bioflows/models"
+	"fmt"
+	"strings"
+)
+
+const (
+	GRAPH_ERROR_DUPLICATE_STEP = "duplicate step"
+	GRAPH_ERROR_UNKNOWN_DEPENDENCY = "unknown dependency"
+	GRAPH_ERROR_CYCLE = "dependency cycle"
+	GRAPH_ERROR_UNKNOWN_REFERENCE = "unknown step reference"
+	GRAPH_ERROR_UNKNOWN_OUTPUT = "unknown step output"
+	GRAPH_ERROR_INVALID_PARAMETER = "invalid parameter"
+)
+
+// GraphError describes a single problem in the structure of a pipeline, Line is zero when the position is not known
+type GraphError struct {
+	Kind string
+	Step string
+	Line int
+	// Dependency is the unknown step referenced by Step
+	Dependency string
+	// Output is the output of Dependency referenced by Step
+	Output string
+	// Path and Lines describe the steps forming a cycle, the first step is repeated at the end
+	Path []string
+	Lines []int
+	// PreviousLine is where a duplicate step has been defined first
+	PreviousLine int
+	// Reason explains why a parameter declaration is invalid
+	Reason string
+}
+
+func formatStep(step string , line int) string {
+	if line > 0 {
+		return fmt.Sprintf("%s (line %d)",step,line)
+	}
+	return step
+}
+
+func (e GraphError) Error() string {
+	switch e.Kind {
+	case GRAPH_ERROR_DUPLICATE_STEP:
+		return fmt.Sprintf("%s: %s has already been defined at %s",e.Kind,formatStep(e.Step,e.Line),formatStep(e.Step,e.PreviousLine))
+	case GRAPH_ERROR_UNKNOWN_DEPENDENCY:
+		return fmt.Sprintf("%s: %s depends on unknown step (%s)",e.Kind,formatStep(e.Step,e.Line),e.Dependency)
+	case GRAPH_ERROR_UNKNOWN_REFERENCE:
+		return fmt.Sprintf("%s: %s references output (%s) of unknown step (%s)",e.Kind,formatStep(e.Step,e.Line),e.Output,e.Dependency)
+	case GRAPH_ERROR_UNKNOWN_OUTPUT:
+		return fmt.Sprintf("%s: %s references output (%s) which is not declared by step (%s)",e.Kind,formatStep(e.Step,e.Line),e.Output,e.Dependency)
+	case GRAPH_ERROR_INVALID_PARAMETER:
+		return fmt.Sprintf("%s: %s %s",e.Kind,formatStep(e.Step,e.Line),e.Reason)
+	case GRAPH_ERROR_CYCLE:
+		steps := make([]string,len(e.Path))
+		for idx , step := range e.Path {
+			steps[idx] = formatStep(step,e.Lines[idx])
+		}
+		return fmt.Sprintf("%s: %s",e.Kind,strings.Join(steps," -> "))
+	default:
+		return fmt.Sprintf("%s: %s",e.Kind,formatStep(e.Step,e.Line))
+	}
+}
+
+// GraphErrors collects all the problems found while building the graph of a pipeline
+type GraphErrors []GraphError
+
+func (e GraphErrors) Error() string {
+	messages := make([]string,len(e))
+	for idx , graphError := range e {
+		messages[idx] = graphError.Error()
+	}
+	return fmt.Sprintf("Invalid pipeline, %d error(s) found:\n%s",len(e),strings.Join(messages,"\n"))
+}
+
+// findCycles returns one error per dependency cycle among the given steps, edges go from a step to its dependencies
+func findCycles(steps []BioPipeline , index map[string]int) GraphErrors {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	errs := make(GraphErrors,0)
+	marks := make(map[string]int)
+	stack := make([]string,0)
+	var visit func(id string)
+	visit = func(id string) {
+		marks[id] = visiting
+		stack = append(stack,id)
+		for _ , dependency := range steps[index[id]].GetDepends() {
+			if _ , ok := index[dependency]; !ok {
+				continue
+			}
+			switch marks[dependency] {
+			case visiting:
+				// The cycle is the part of the stack starting at the dependency, it is reported in execution order
+				start := 0
+				for idx , stepId := range stack {
+					if stepId == dependency {
+						start = idx
+					}
+				}
+				cycle := append([]string{},stack[start:]...)
+				path := make([]string,0,len(cycle)+1)
+				for idx := len(cycle) - 1 ; idx >= 0 ; idx-- {
+					path = append(path,cycle[idx])
+				}
+				path = append(path,path[0])
+				lines := make([]int,len(path))
+				for idx , stepId := range path {
+					lines[idx] = steps[index[stepId]].Line
+				}
+				errs = append(errs,GraphError{Kind: GRAPH_ERROR_CYCLE,Step: dependency,Line: steps[index[dependency]].Line,Path: path,Lines: lines})
+			case unvisited:
+				visit(dependency)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		marks[id] = visited
+	}
+	for _ , step := range steps {
+		if marks[step.ID] == unvisited {
+			visit(step.ID)
+		}
+	}
+	return errs
+}
+
+// validateParameters reports the invalid parameter declarations of the given step
+func validateParameters(step BioPipeline) GraphErrors {
+	errs := make(GraphErrors,0)
+	for _ , params := range [][]models.Parameter{step.Inputs,step.Config,step.Outputs} {
+		for _ , param := range params {
+			if err := param.Validate(); err != nil {
+				errs = append(errs,GraphError{Kind: GRAPH_ERROR_INVALID_PARAMETER,Step: step.ID,Line: step.Line,Reason: err.Error()})
+			}
+			if warning := param.TypeWarning(); len(warning) > 0 {
+				fmt.Println(fmt.Sprintf("Warning: %s %s",formatStep(step.ID,step.Line),warning))
+			}
+		}
+	}
+	if len(step.Stdout) > 0 && !step.DeclaresOutput(step.Stdout) && (len(step.URL) == 0 || len(step.Outputs) > 0) {
+		errs = append(errs,GraphError{Kind: GRAPH_ERROR_INVALID_PARAMETER,Step: step.ID,Line: step.Line,
+			Reason: fmt.Sprintf("pipes its stdout into output (%s) which is not declared",step.Stdout)})
+	}
+	if _ , err := step.GetTimeout(); err != nil {
+		errs = append(errs,GraphError{Kind: GRAPH_ERROR_INVALID_PARAMETER,Step: step.ID,Line: step.Line,
+			Reason: fmt.Sprintf("has an invalid timeout (%s): %s",step.Timeout,err.Error())})
+	}
+	if err := step.Retry.Validate(); err != nil {
+		errs = append(errs,GraphError{Kind: GRAPH_ERROR_INVALID_PARAMETER,Step: step.ID,Line: step.Line,Reason: err.Error()})
+	}
+	if _ , err := models.GetShell(step.Shell); err != nil {
+		errs = append(errs,GraphError{Kind: GRAPH_ERROR_INVALID_PARAMETER,Step: step.ID,Line: step.Line,Reason: err.Error()})
+	}
+	if _ , err := models.GetPullPolicy(step.PullPolicy); err != nil {
+		errs = append(errs,GraphError{Kind: GRAPH_ERROR_INVALID_PARAMETER,Step: step.ID,Line: step.Line,Reason: err.Error()})
+	}
+	if step.Publish != nil {
+		if _ , err := step.Publish.GetMode(); err != nil {
+			errs = append(errs,GraphError{Kind: GRAPH_ERROR_INVALID_PARAMETER,Step: step.ID,Line: step.Line,Reason: err.Error()})
+		}
+		for _ , name := range step.Publish.Outputs {
+			if !step.DeclaresOutput(name) && (len(step.URL) == 0 || len(step.Outputs) > 0) {
+				errs = append(errs,GraphError{Kind: GRAPH_ERROR_INVALID_PARAMETER,Step: step.ID,Line: step.Line,
+					Reason: fmt.Sprintf("publishes output (%s) which is not declared",name)})
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,85 @@
+package pipelines
+
+import (
+	"reflect"
+	"testing"
+)
+
+func indexSteps(steps []BioPipeline) map[string]int {
+	index := make(map[string]int)
+	for idx , step := range steps {
+		index[step.ID] = idx
+	}
+	return index
+}
+
+func TestFindCycles(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []BioPipeline
+		paths [][]string
+	}{
+		{
+			name: "acyclic",
+			steps: []BioPipeline{{ID: "a"},{ID: "b",Depends: "a"},{ID: "c",Depends: "a,b"}},
+		},
+		{
+			name: "self dependency",
+			steps: []BioPipeline{{ID: "a",Depends: "a"}},
+			paths: [][]string{{"a","a"}},
+		},
+		{
+			name: "two steps",
+			steps: []BioPipeline{{ID: "a",Depends: "b"},{ID: "b",Depends: "a"}},
+			paths: [][]string{{"b","a","b"}},
+		},
+		{
+			name: "three steps in execution order",
+			steps: []BioPipeline{{ID: "a",Depends: "c"},{ID: "b",Depends: "a"},{ID: "c",Depends: "b"}},
+			paths: [][]string{{"b","c","a","b"}},
+		},
+		{
+			name: "separate cycles",
+			steps: []BioPipeline{{ID: "a",Depends: "b"},{ID: "b",Depends: "a"},{ID: "c",Depends: "d"},{ID: "d",Depends: "c"}},
+			paths: [][]string{{"b","a","b"},{"d","c","d"}},
+		},
+		{
+			name: "unknown dependencies are ignored",
+			steps: []BioPipeline{{ID: "a",Depends: "missing"}},
+		},
+	}
+	for _ , test := range tests {
+		t.Run(test.name,func(t *testing.T){
+			errs := findCycles(test.steps,indexSteps(test.steps))
+			if len(errs) != len(test.paths) {
+				t.Fatalf("expected %d cycle(s), got %v",len(test.paths),errs)
+			}
+			for idx , err := range errs {
+				if err.Kind != GRAPH_ERROR_CYCLE {
+					t.Errorf("unexpected error kind (%s)",err.Kind)
+				}
+				if !reflect.DeepEqual(err.Path,test.paths[idx]) {
+					t.Errorf("expected cycle %v, got %v",test.paths[idx],err.Path)
+				}
+				if len(err.Lines) != len(err.Path) {
+					t.Errorf("expected a line for every step of the cycle, got %v",err.Lines)
+				}
+			}
+		})
+	}
+}
+
+func TestFindCyclesLines(t *testing.T) {
+	steps := []BioPipeline{{ID: "a",Depends: "b",Line: 3},{ID: "b",Depends: "a",Line: 7}}
+	errs := findCycles(steps,indexSteps(steps))
+	if len(errs) != 1 {
+		t.Fatalf("expected a single cycle, got %v",errs)
+	}
+	if !reflect.DeepEqual(errs[0].Lines,[]int{7,3,7}) {
+		t.Errorf("unexpected lines %v",errs[0].Lines)
+	}
+	expected := "dependency cycle: b (line 7) -> a (line 3) -> b (line 7)"
+	if errs[0].Error() != expected {
+		t.Errorf("expected (%s), got (%s)",expected,errs[0].Error())
+	}
+}
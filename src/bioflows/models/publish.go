@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	PUBLISH_COPY     = "copy"
+	PUBLISH_HARDLINK = "hardlink"
+	PUBLISH_SYMLINK  = "symlink"
+)
+
+/*
+	Publish selects the outputs of a tool which are placed into the publish directory of the workflow once the tool succeeds.
+	Path is where they are placed relative to the publish directory, it is rendered against the parameters of the tool
+	and defaults to the ID of the tool. All outputs are published when no outputs are listed.
+ */
+type Publish struct {
+	Mode    string   `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Path    string   `json:"path,omitempty" yaml:"path,omitempty"`
+	Outputs []string `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+}
+
+// GetMode returns how the outputs are published, defaults to copy
+func (p *Publish) GetMode() (string,error) {
+	switch strings.ToLower(p.Mode) {
+	case "" , PUBLISH_COPY:
+		return PUBLISH_COPY , nil
+	case PUBLISH_HARDLINK:
+		return PUBLISH_HARDLINK , nil
+	case PUBLISH_SYMLINK:
+		return PUBLISH_SYMLINK , nil
+	default:
+		return "" , fmt.Errorf("unknown publish mode (%s), it should be one of %s, %s or %s",p.Mode,PUBLISH_COPY,PUBLISH_HARDLINK,PUBLISH_SYMLINK)
+	}
+}
+
+// Publishes tells whether the output with the given name is selected
+func (p *Publish) Publishes(name string) bool {
+	if len(p.Outputs) == 0 {
+		return true
+	}
+	for _ , output := range p.Outputs {
+		if output == name {
+			return true
+		}
+	}
+	return false
+}
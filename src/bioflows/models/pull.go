@@ -0,0 +1,26 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	PULL_ALWAYS = "always"
+	PULL_IF_NOT_PRESENT = "if-not-present"
+	PULL_NEVER = "never"
+)
+
+// GetPullPolicy normalizes the pull policy of a container image, images are only pulled when they are missing if it is not set
+func GetPullPolicy(policy string) (string,error) {
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "" , PULL_IF_NOT_PRESENT:
+		return PULL_IF_NOT_PRESENT , nil
+	case PULL_ALWAYS:
+		return PULL_ALWAYS , nil
+	case PULL_NEVER:
+		return PULL_NEVER , nil
+	default:
+		return "" , fmt.Errorf("unknown pull policy (%s), it should be one of %s, %s or %s",policy,PULL_ALWAYS,PULL_IF_NOT_PRESENT,PULL_NEVER)
+	}
+}
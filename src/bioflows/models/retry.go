@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	RETRY_BACKOFF_FIXED       = "fixed"
+	RETRY_BACKOFF_EXPONENTIAL = "exponential"
+
+	DEFAULT_RETRY_DELAY = time.Second
+)
+
+/*
+	RetryPolicy is the BDL "retry" directive of a tool or a step, e.g.
+
+	retry:
+	  attempts: 3
+	  backoff: exponential
+	  delay: 30s
+	  max_delay: 10m
+	  exit_codes: [1,137]
+	  escalate:
+	    memory: 8192
+
+	attempts is the total number of attempts including the first one, exit_codes lists the retryable exit codes
+	(any failure is retried if it is empty) and escalate is added to the declared caps on every new attempt.
+ */
+type RetryPolicy struct {
+	Attempts int `json:"attempts,omitempty" yaml:"attempts,omitempty"`
+	Backoff string `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	Delay string `json:"delay,omitempty" yaml:"delay,omitempty"`
+	MaxDelay string `json:"max_delay,omitempty" yaml:"max_delay,omitempty"`
+	ExitCodes []int `json:"exit_codes,omitempty" yaml:"exit_codes,omitempty"`
+	Escalate *Capabilities `json:"escalate,omitempty" yaml:"escalate,omitempty"`
+}
+
+// ShouldRetry decides whether another attempt is allowed after the given attempt has failed with the given exit code
+func (r *RetryPolicy) ShouldRetry(attempt int , exitCode int) bool {
+	if r == nil || attempt >= r.Attempts {
+		return false
+	}
+	if len(r.ExitCodes) == 0 {
+		return true
+	}
+	for _ , code := range r.ExitCodes {
+		if code == exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the backoff and the durations of the policy, so they are not silently replaced by their defaults
+func (r *RetryPolicy) Validate() error {
+	if r == nil {
+		return nil
+	}
+	if len(r.Backoff) > 0 && r.Backoff != RETRY_BACKOFF_FIXED && r.Backoff != RETRY_BACKOFF_EXPONENTIAL {
+		return fmt.Errorf("retry has unknown backoff (%s), it should be %s or %s",r.Backoff,RETRY_BACKOFF_FIXED,RETRY_BACKOFF_EXPONENTIAL)
+	}
+	if len(r.Delay) > 0 {
+		if _ , err := time.ParseDuration(r.Delay); err != nil {
+			return fmt.Errorf("retry has an invalid delay (%s): %s",r.Delay,err.Error())
+		}
+	}
+	if len(r.MaxDelay) > 0 {
+		if _ , err := time.ParseDuration(r.MaxDelay); err != nil {
+			return fmt.Errorf("retry has an invalid max_delay (%s): %s",r.MaxDelay,err.Error())
+		}
+	}
+	return nil
+}
+
+// GetDelay returns how long to wait after the given failed attempt before starting the next one
+func (r *RetryPolicy) GetDelay(attempt int) time.Duration {
+	if r == nil {
+		return 0
+	}
+	delay := DEFAULT_RETRY_DELAY
+	if len(r.Delay) > 0 {
+		if parsed , err := time.ParseDuration(r.Delay); err == nil {
+			delay = parsed
+		}
+	}
+	// The delay never grows beyond max_delay, or beyond what a duration can hold
+	maxDelay := time.Duration(math.MaxInt64)
+	if len(r.MaxDelay) > 0 {
+		if parsed , err := time.ParseDuration(r.MaxDelay); err == nil {
+			maxDelay = parsed
+		}
+	}
+	if r.Backoff == RETRY_BACKOFF_EXPONENTIAL {
+		for i := 1 ; i < attempt && delay < maxDelay ; i++ {
+			if delay > maxDelay / 2 {
+				delay = maxDelay
+				break
+			}
+			delay *= 2
+		}
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// GetCaps returns the caps to request for the given attempt, the escalation is added once per retry
+func (r *RetryPolicy) GetCaps(caps *Capabilities , attempt int) *Capabilities {
+	if r == nil || r.Escalate == nil || attempt <= 1 {
+		return caps
+	}
+	escalated := &Capabilities{}
+	if caps != nil {
+		*escalated = *caps
+	}
+	escalated.CPU += r.Escalate.CPU * (attempt - 1)
+	escalated.Memory += r.Escalate.Memory * (attempt - 1)
+	return escalated
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyGetDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *RetryPolicy
+		attempt  int
+		expected time.Duration
+	}{
+		{name: "no policy",policy: nil,attempt: 1,expected: 0},
+		{name: "default delay",policy: &RetryPolicy{},attempt: 3,expected: DEFAULT_RETRY_DELAY},
+		{name: "fixed",policy: &RetryPolicy{Backoff: RETRY_BACKOFF_FIXED,Delay: "30s"},attempt: 4,expected: 30 * time.Second},
+		{name: "exponential first attempt",policy: &RetryPolicy{Backoff: RETRY_BACKOFF_EXPONENTIAL,Delay: "10s"},attempt: 1,expected: 10 * time.Second},
+		{name: "exponential",policy: &RetryPolicy{Backoff: RETRY_BACKOFF_EXPONENTIAL,Delay: "10s"},attempt: 4,expected: 80 * time.Second},
+		{name: "exponential capped",policy: &RetryPolicy{Backoff: RETRY_BACKOFF_EXPONENTIAL,Delay: "10s",MaxDelay: "1m"},attempt: 4,expected: time.Minute},
+		{name: "fixed capped",policy: &RetryPolicy{Delay: "5m",MaxDelay: "1m"},attempt: 1,expected: time.Minute},
+		{name: "exponential overflow",policy: &RetryPolicy{Backoff: RETRY_BACKOFF_EXPONENTIAL,Delay: "1h"},attempt: 100,expected: time.Duration(math.MaxInt64)},
+	}
+	for _ , test := range tests {
+		if actual := test.policy.GetDelay(test.attempt); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v",test.name,test.expected,actual)
+		}
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	valid := []*RetryPolicy{nil,{},{Backoff: RETRY_BACKOFF_EXPONENTIAL,Delay: "1s",MaxDelay: "1m"}}
+	for _ , policy := range valid {
+		if err := policy.Validate(); err != nil {
+			t.Errorf("unexpected error for %+v: %s",policy,err.Error())
+		}
+	}
+	invalid := []*RetryPolicy{{Backoff: "linear"},{Delay: "soon"},{MaxDelay: "10"}}
+	for _ , policy := range invalid {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("expected an error for %+v",policy)
+		}
+	}
+}
+
+func TestRetryPolicyGetCaps(t *testing.T) {
+	caps := &Capabilities{CPU: 2,Memory: 1024}
+	policy := &RetryPolicy{Escalate: &Capabilities{CPU: 1,Memory: 2048}}
+	if actual := policy.GetCaps(caps,1); actual != caps {
+		t.Errorf("the first attempt should keep the declared caps, got %+v",actual)
+	}
+	if actual := policy.GetCaps(caps,3); actual.CPU != 4 || actual.Memory != 5120 {
+		t.Errorf("expected cpu 4 and memory 5120, got %+v",actual)
+	}
+	if caps.CPU != 2 || caps.Memory != 1024 {
+		t.Errorf("the declared caps have been modified: %+v",caps)
+	}
+	if actual := policy.GetCaps(nil,2); actual.CPU != 1 || actual.Memory != 2048 {
+		t.Errorf("expected the escalation alone without declared caps, got %+v",actual)
+	}
+	if actual := (&RetryPolicy{}).GetCaps(caps,5); actual != caps {
+		t.Errorf("a policy without escalation should keep the declared caps, got %+v",actual)
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	policy := &RetryPolicy{Attempts: 3,ExitCodes: []int{137}}
+	if !policy.ShouldRetry(1,137) || policy.ShouldRetry(1,1) || policy.ShouldRetry(3,137) {
+		t.Error("unexpected retry decision")
+	}
+	timeouts := &RetryPolicy{Attempts: 2,ExitCodes: []int{EXIT_CODE_TIMEOUT}}
+	if !timeouts.ShouldRetry(1,EXIT_CODE_TIMEOUT) {
+		t.Error("a timed out attempt should be retried when its exit code is listed")
+	}
+	if (*RetryPolicy)(nil).ShouldRetry(1,1) {
+		t.Error("a step without a policy should not be retried")
+	}
+}
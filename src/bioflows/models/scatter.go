@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	SCATTER_DOT   = "dot"
+	SCATTER_CROSS = "cross"
+)
+
+/*
+	Scatter returns the bindings of every iteration of a loop over the given variables, each binding holds
+	a "<variable>_item" entry for every scattered variable. In the dot mode (default) the lists are zipped
+	so they should have the same length, e.g. R1 and R2 reads of the same samples. In the cross mode every
+	element of a list is paired with every element of the other lists, the first variable changes the slowest.
+ */
+func Scatter(config map[string]interface{} , variables []string , mode string) ([]FlowConfig,error) {
+	if len(variables) == 0 {
+		return nil , fmt.Errorf("Loop is defined but no loop variable has been defined")
+	}
+	lists := make([][]interface{},len(variables))
+	for idx , variable := range variables {
+		value , ok := config[variable]
+		if !ok {
+			return nil , fmt.Errorf("Loop Variable is not defined: %v",variable)
+		}
+		elements , islist := value.([]interface{})
+		if !islist {
+			return nil , fmt.Errorf("Loop Variable (%s) does not hold a list of elements",variable)
+		}
+		lists[idx] = elements
+	}
+	bindings := make([]FlowConfig,0)
+	switch strings.ToLower(mode) {
+	case "" , SCATTER_DOT:
+		for idx , elements := range lists {
+			if len(elements) != len(lists[0]) {
+				return nil , fmt.Errorf("Dot product scatter requires lists of the same length, (%s) has %d elements while (%s) has %d",
+					variables[0],len(lists[0]),variables[idx],len(elements))
+			}
+		}
+		for position := range lists[0] {
+			binding := FlowConfig{}
+			for idx , variable := range variables {
+				binding[fmt.Sprintf("%s_item",variable)] = lists[idx][position]
+			}
+			bindings = append(bindings,binding)
+		}
+	case SCATTER_CROSS:
+		bindings = append(bindings,FlowConfig{})
+		for idx , variable := range variables {
+			product := make([]FlowConfig,0,len(bindings)*len(lists[idx]))
+			for _ , partial := range bindings {
+				for _ , element := range lists[idx] {
+					binding := FlowConfig{}
+					binding.Fill(partial)
+					binding[fmt.Sprintf("%s_item",variable)] = element
+					product = append(product,binding)
+				}
+			}
+			bindings = product
+		}
+	default:
+		return nil , fmt.Errorf("Unknown scatter mode (%s), it should be either %s or %s",mode,SCATTER_DOT,SCATTER_CROSS)
+	}
+	return bindings , nil
+}
+
+// GetLoopVars returns the variables scattered by a loop, the scatter directive takes precedence over loop_var
+func GetLoopVars(loopVar string , scatter []string) []string {
+	if len(scatter) > 0 {
+		return scatter
+	}
+	if len(loopVar) > 0 {
+		return []string{loopVar}
+	}
+	return []string{}
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScatterDot(t *testing.T) {
+	config := map[string]interface{}{
+		"reads": []interface{}{"r1","r2"},
+		"sample": []interface{}{"s1","s2"},
+	}
+	for _ , mode := range []string{"",SCATTER_DOT,"DOT"} {
+		bindings , err := Scatter(config,[]string{"reads","sample"},mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []FlowConfig{
+			{"reads_item": "r1","sample_item": "s1"},
+			{"reads_item": "r2","sample_item": "s2"},
+		}
+		if !reflect.DeepEqual(bindings,expected) {
+			t.Errorf("mode (%s): expected %v, got %v",mode,expected,bindings)
+		}
+	}
+}
+
+func TestScatterCross(t *testing.T) {
+	config := map[string]interface{}{
+		"reads": []interface{}{"r1","r2"},
+		"genome": []interface{}{"hg19","hg38","mm10"},
+	}
+	bindings , err := Scatter(config,[]string{"reads","genome"},SCATTER_CROSS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []FlowConfig{
+		{"reads_item": "r1","genome_item": "hg19"},
+		{"reads_item": "r1","genome_item": "hg38"},
+		{"reads_item": "r1","genome_item": "mm10"},
+		{"reads_item": "r2","genome_item": "hg19"},
+		{"reads_item": "r2","genome_item": "hg38"},
+		{"reads_item": "r2","genome_item": "mm10"},
+	}
+	if !reflect.DeepEqual(bindings,expected) {
+		t.Errorf("expected %v, got %v",expected,bindings)
+	}
+}
+
+func TestScatterErrors(t *testing.T) {
+	config := map[string]interface{}{
+		"reads": []interface{}{"r1","r2"},
+		"genome": []interface{}{"hg38"},
+		"sample": "s1",
+	}
+	tests := []struct {
+		variables []string
+		mode      string
+	}{
+		{variables: nil},
+		{variables: []string{"missing"}},
+		{variables: []string{"sample"}},
+		{variables: []string{"reads","genome"},mode: SCATTER_DOT},
+		{variables: []string{"reads"},mode: "zip"},
+	}
+	for _ , test := range tests {
+		if _ , err := Scatter(config,test.variables,test.mode); err == nil {
+			t.Errorf("expected an error for %v with mode (%s)",test.variables,test.mode)
+		}
+	}
+}
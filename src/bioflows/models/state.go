@@ -0,0 +1,29 @@
+package models
+
+/*
+	Step states are saved under the "state" key of every step configuration in the state manager,
+	they complement the boolean "status" key by telling why a step did not succeed.
+ */
+const (
+	STEP_STATE_KEY = "state"
+	STEP_ATTEMPTS_KEY = "attempts"
+	STEP_CACHE_KEY = "cache_key"
+	STEP_CACHED_KEY = "cached"
+	STEP_ALLOWED_FAILURE_KEY = "allowed_failure"
+	STEP_USAGE_KEY = "usage"
+	STEP_FAILURE_REASON_KEY = "failure_reason"
+	// STEP_ITERATIONS_KEY holds the state of every iteration of a looping tool, in the order of the iterations
+	STEP_ITERATIONS_KEY = "iterations"
+
+	FAILURE_REASON_OOM_KILLED = "oom_killed"
+
+	// EXIT_CODE_TIMEOUT is the exit code of a step killed by its timeout, like GNU timeout, so retry exit_codes can match it
+	EXIT_CODE_TIMEOUT = 124
+
+	STEP_STATE_SUCCEEDED = "succeeded"
+	STEP_STATE_FAILED    = "failed"
+	STEP_STATE_CANCELLED = "cancelled"
+	STEP_STATE_TIMEOUT   = "timeout"
+	STEP_STATE_SKIPPED   = "skipped"
+	STEP_STATE_BLOCKED   = "blocked"
+)
@@ -1,6 +1,9 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Tool struct {
 	Type         string        `json:"type,omitempty" yaml:"type,omitempty"`
@@ -22,16 +25,81 @@ type Tool struct {
 	Inputs       []Parameter   `json:"inputs,omitempty" yaml:"inputs,omitempty"`
 	Config       []Parameter   `json:"config,omitempty" yaml:"config,omitempty"`
 	Outputs      []Parameter   `json:"outputs,omitempty" yaml:"outputs,omitempty"`
-	Command      Scriptable    `json:"command" yaml:"command"`
+	Command      Command       `json:"command" yaml:"command"`
+	Shell        string        `json:"shell,omitempty" yaml:"shell,omitempty"`
 	Loop bool 	`json:"loop,omitempty" yaml:"loop,omitempty"`
 	LoopVar string `json:"loop_var,omitempty" yaml:"loop_var,omitempty"`
+	Scatter []string `json:"scatter,omitempty" yaml:"scatter,omitempty"`
+	ScatterMode string `json:"scatter_mode,omitempty" yaml:"scatter_mode,omitempty"`
+	Parallelism int `json:"parallelism,omitempty" yaml:"parallelism,omitempty"`
 	Dependencies []string      `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
 	Deprecated   bool          `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
 	Conditions   []Scriptable  `json:"conditions,omitempty" yaml:"conditions,omitempty"`
 	Scripts      []Script      `json:"scripts,omitempty" yaml:"scripts,omitempty"`
 	Notification *Notification `json:"notification,omitempty" yaml:"notification,omitempty"`
 	Caps         *Capabilities `json:"caps,omitempty" yaml:"caps,omitempty"`
+	Retry        *RetryPolicy  `json:"retry,omitempty" yaml:"retry,omitempty"`
+	Timeout      string        `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Cache        *bool         `json:"cache,omitempty" yaml:"cache,omitempty"`
+	Env          map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	Stdout       string        `json:"stdout,omitempty" yaml:"stdout,omitempty"`
+	Tee          bool          `json:"tee,omitempty" yaml:"tee,omitempty"`
+	Scratch      bool          `json:"scratch,omitempty" yaml:"scratch,omitempty"`
+	KeepScratch  bool          `json:"keep_scratch,omitempty" yaml:"keep_scratch,omitempty"`
+	Publish      *Publish      `json:"publish,omitempty" yaml:"publish,omitempty"`
 	ContainerConfig *ContainerConfig `json:"container,omitempty" yaml:"container,omitempty"`
+	PullPolicy   string        `json:"pull_policy,omitempty" yaml:"pull_policy,omitempty"`
+}
+
+// GetTimeout parses the timeout directive of the tool (e.g. 90m, 2h30m), zero means no timeout
+func (t *Tool) GetTimeout() (time.Duration,error) {
+	if len(t.Timeout) == 0 {
+		return 0 , nil
+	}
+	return time.ParseDuration(t.Timeout)
+}
+
+// MergeEnv returns the environment variables of the parent overridden by the ones of the child
+func MergeEnv(parent map[string]string , child map[string]string) map[string]string {
+	if len(parent) == 0 && len(child) == 0 {
+		return nil
+	}
+	env := make(map[string]string)
+	for name , value := range parent {
+		env[name] = value
+	}
+	for name , value := range child {
+		env[name] = value
+	}
+	return env
+}
+
+// DeclaresOutput tells whether the tool declares an output with the given name
+func (t *Tool) DeclaresOutput(name string) bool {
+	for _ , param := range t.Outputs {
+		if param.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLoop tells whether the tool runs once per element of its loop variables
+func (t *Tool) IsLoop() bool {
+	return t.Loop || len(t.Scatter) > 0
+}
+
+// GetLoopVars returns the variables the tool loops over
+func (t *Tool) GetLoopVars() []string {
+	return GetLoopVars(t.LoopVar,t.Scatter)
+}
+
+// IsCacheable tells whether the results of the tool could be restored from a previous identical run, defaults to true
+func (t *Tool) IsCacheable() bool {
+	if t.Cache == nil {
+		return true
+	}
+	return *t.Cache
 }
 
 func (t *Tool) ToJson() string {
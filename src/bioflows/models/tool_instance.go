@@ -11,8 +11,8 @@ type ToolInstance struct {
 	Name string `json:"name"`
 	WorkflowID string `json:"workflowId"`
 	WorkflowName string `json:"workflowName"`
-	StartTime time.Duration
-	EndTime time.Duration
+	StartTime time.Time `json:"startTime,omitempty"`
+	EndTime time.Time `json:"endTime,omitempty"`
 	Status int `json:"status"`
 
 }
@@ -39,7 +39,7 @@ func (instance *ToolInstance) Prepare() {
 }
 
 func (instance ToolInstance) PrepareCommand() []string{
-	splitted_command := strings.Split(string(instance.Command)," ")
+	splitted_command := strings.Split(instance.Command.ToString()," ")
 	if splitted_command[0] == "sudo"{
 		splitted_command = splitted_command[1:]
 	}
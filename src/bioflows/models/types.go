@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/docker/docker/api/types"
+	"os"
+	"strings"
 )
 
 type FlowConfig map[string]interface{}
@@ -53,6 +55,13 @@ type Parameter struct {
 	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
 	Value       interface{} `json:"value,omitempty" yaml:"value,omitempty"`
 	Attachable *bool 		`json:"attach,omitempty" yaml:"attach,omitempty"`
+	Required    bool        `json:"required,omitempty" yaml:"required,omitempty"`
+	Optional    bool        `json:"optional,omitempty" yaml:"optional,omitempty"`
+	Default     interface{} `json:"default,omitempty" yaml:"default,omitempty"`
+	Min         *float64    `json:"min,omitempty" yaml:"min,omitempty"`
+	Max         *float64    `json:"max,omitempty" yaml:"max,omitempty"`
+	Pattern     string      `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Choices     []interface{} `json:"choices,omitempty" yaml:"choices,omitempty"`
 }
 func (p *Parameter) IsAttachable() bool {
 	if p.Attachable == nil {
@@ -81,6 +90,27 @@ func (p *Parameter) Fill(t *Parameter) error {
 	if t.Attachable != nil {
 		*p.Attachable = *t.Attachable
 	}
+	if t.Required {
+		p.Required = t.Required
+	}
+	if t.Optional {
+		p.Optional = t.Optional
+	}
+	if t.Default != nil {
+		p.Default = t.Default
+	}
+	if t.Min != nil {
+		p.Min = t.Min
+	}
+	if t.Max != nil {
+		p.Max = t.Max
+	}
+	if t.Pattern != "" {
+		p.Pattern = t.Pattern
+	}
+	if len(t.Choices) > 0 {
+		p.Choices = t.Choices
+	}
 	return nil
 }
 
@@ -105,11 +135,37 @@ type Maintainer struct {
 	Email    string `json:"email,omitempty" yaml:"email,omitempty"`
 }
 
+const (
+	NOTIFY_ON_START   = "start"
+	NOTIFY_ON_SUCCESS = "success"
+	NOTIFY_ON_FAILURE = "failure"
+)
+
+/*
+	Notification is sent to every channel it declares: an email when To is set, a JSON POST request when Webhook is set
+	and a JSON line appended to File when it is set. The title and the body are mustache templates.
+ */
 type Notification struct {
 	To    string `json:"to" yaml:"to"`
 	CC    string `json:"cc,omitempty" yaml:"cc,omitempty"`
 	Title string `json:"title" yaml:"title"`
 	Body  string `json:"body" yaml:"body"`
+	On    []string `json:"on,omitempty" yaml:"on,omitempty"`
+	Webhook string `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+	File  string `json:"file,omitempty" yaml:"file,omitempty"`
+}
+
+// ShouldNotify tells whether the notification is triggered by the given event, it is sent on success and failure by default
+func (n *Notification) ShouldNotify(event string) bool {
+	if len(n.On) == 0 {
+		return event == NOTIFY_ON_SUCCESS || event == NOTIFY_ON_FAILURE
+	}
+	for _ , trigger := range n.On {
+		if strings.EqualFold(strings.TrimSpace(trigger),event) {
+			return true
+		}
+	}
+	return false
 }
 
 type Capabilities struct {
@@ -117,10 +173,42 @@ type Capabilities struct {
 	Memory int `json:"memory,omitempty" yaml:"memory,omitempty"`
 }
 
+// Volume is an extra host path mounted into the containers, the target defaults to the same path as the source
+type Volume struct {
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	Target string `json:"target,omitempty" yaml:"target,omitempty"`
+	ReadOnly bool `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+}
+
+func (v Volume) GetTarget() string {
+	if len(v.Target) == 0 {
+		return v.Source
+	}
+	return v.Target
+}
+
 type ContainerConfig struct {
 	Username string `json:"username,omitempty" yaml:"username,omitempty"`
 	Password string `json:"password,omitempty" yaml:"password,omitempty"`
 	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+	// User runs the containers as the given user (e.g. root or 1000:1000) instead of the host user invoking BioFlows
+	User string `json:"user,omitempty" yaml:"user,omitempty"`
+	Workdir string `json:"workdir,omitempty" yaml:"workdir,omitempty"`
+	Entrypoint []string `json:"entrypoint,omitempty" yaml:"entrypoint,omitempty"`
+	Volumes []Volume `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+}
+
+// GetUser returns the user running the containers, the UID:GID of the current host user unless another user is configured
+func (c *ContainerConfig) GetUser() string {
+	if c != nil && len(c.User) > 0 {
+		return c.User
+	}
+	uid , gid := os.Getuid() , os.Getgid()
+	if uid < 0 || gid < 0 {
+		// There are no user IDs on this platform, the user of the image is kept
+		return ""
+	}
+	return fmt.Sprintf("%d:%d",uid,gid)
 }
 
 func (c *ContainerConfig) GetAuth() (string,error) {
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+)
+
+// ResourceUsage is what a step has consumed while it was running, PeakRSS is in bytes
+type ResourceUsage struct {
+	WallTime   time.Duration
+	UserTime   time.Duration
+	SystemTime time.Duration
+	PeakRSS    int64
+}
+
+// Combine adds the cpu times of the other usage to the current one and keeps the highest peak, the wall time is left untouched
+func (u *ResourceUsage) Combine(other ResourceUsage) {
+	u.UserTime += other.UserTime
+	u.SystemTime += other.SystemTime
+	if other.PeakRSS > u.PeakRSS {
+		u.PeakRSS = other.PeakRSS
+	}
+}
+
+// ToMap returns the usage as it is stored in the step state, times are in seconds and the peak RSS in MB
+func (u ResourceUsage) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"wall_time": u.WallTime.Seconds(),
+		"user_time": u.UserTime.Seconds(),
+		"system_time": u.SystemTime.Seconds(),
+		"peak_rss_mb": float64(u.PeakRSS) / (1024 * 1024),
+	}
+}
+
+func toSeconds(value interface{}) time.Duration {
+	switch v := value.(type) {
+	case float64:
+		return time.Duration(v * float64(time.Second))
+	case int:
+		return time.Duration(v) * time.Second
+	}
+	return 0
+}
+
+// GetUsageFromState reads the usage stored in the given step state, it returns false if there is none
+func GetUsageFromState(state interface{}) (ResourceUsage,bool) {
+	usage := ResourceUsage{}
+	var stepState map[string]interface{}
+	switch s := state.(type) {
+	case map[string]interface{}:
+		stepState = s
+	case FlowConfig:
+		stepState = s
+	default:
+		return usage , false
+	}
+	data , ok := stepState[STEP_USAGE_KEY].(map[string]interface{})
+	if !ok {
+		return usage , false
+	}
+	usage.WallTime = toSeconds(data["wall_time"])
+	usage.UserTime = toSeconds(data["user_time"])
+	usage.SystemTime = toSeconds(data["system_time"])
+	if peak , ok := data["peak_rss_mb"].(float64); ok {
+		usage.PeakRSS = int64(peak * 1024 * 1024)
+	}
+	return usage , true
+}
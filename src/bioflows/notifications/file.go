@@ -0,0 +1,37 @@
+package notifications
+
+import (
+	"bioflows/config"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileMutex keeps the lines written by steps running at the same time apart
+var fileMutex sync.Mutex
+
+// FileNotifier appends notifications as JSON lines to the given file
+type FileNotifier struct {
+	Path string
+}
+
+func (f *FileNotifier) Notify(message *Message) error {
+	line , err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	fileMutex.Lock()
+	defer fileMutex.Unlock()
+	err = os.MkdirAll(filepath.Dir(f.Path),config.FILE_MODE_WRITABLE_PERM)
+	if err != nil {
+		return err
+	}
+	file , err := os.OpenFile(f.Path,os.O_CREATE|os.O_WRONLY|os.O_APPEND,0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_ , err = file.Write(append(line,'\n'))
+	return err
+}
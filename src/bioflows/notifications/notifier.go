@@ -0,0 +1,108 @@
+package notifications
+
+import (
+	"bioflows/expr"
+	"bioflows/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message is a notification rendered for an event of a step or a pipeline
+type Message struct {
+	Event  string    `json:"event"`
+	Step   string    `json:"step"`
+	State  string    `json:"state,omitempty"`
+	Status bool      `json:"status"`
+	Title  string    `json:"title"`
+	Body   string    `json:"body"`
+	To     []string  `json:"-"`
+	CC     []string  `json:"-"`
+	Time   time.Time `json:"time"`
+}
+
+// Notifier delivers messages to a single channel
+type Notifier interface {
+	Notify(message *Message) error
+}
+
+func splitAddresses(addresses string) []string {
+	result := make([]string,0)
+	for _ , address := range strings.Split(addresses,",") {
+		address = strings.TrimSpace(address)
+		if len(address) > 0 {
+			result = append(result,address)
+		}
+	}
+	return result
+}
+
+// GetNotifiers returns a notifier for every channel of the notification, emails are sent through the email section of the system configuration
+func GetNotifiers(notification *models.Notification , systemConfig models.FlowConfig) ([]Notifier,error) {
+	notifiers := make([]Notifier,0)
+	if len(splitAddresses(notification.To)) > 0 {
+		section , ok := systemConfig["email"]
+		if !ok {
+			return notifiers , fmt.Errorf("email notifications require the email section of BioFlows configuration")
+		}
+		smtpNotifier , err := NewSMTPNotifier(section)
+		if err != nil {
+			return notifiers , err
+		}
+		notifiers = append(notifiers,smtpNotifier)
+	}
+	if len(notification.Webhook) > 0 {
+		notifiers = append(notifiers,NewWebhookNotifier(notification.Webhook))
+	}
+	if len(notification.File) > 0 {
+		notifiers = append(notifiers,&FileNotifier{Path: notification.File})
+	}
+	return notifiers , nil
+}
+
+/*
+	Send renders the notification for the given event of a step against its configuration and delivers it to all of its channels.
+	The templates can use the configuration of the step along with {{event}}, {{step}}, {{status}} and {{state}}.
+	A failing channel does not prevent the others from being notified, all errors are returned together.
+ */
+func Send(notification *models.Notification , event string , step string , stepConfig models.FlowConfig , systemConfig models.FlowConfig) error {
+	if notification == nil || !notification.ShouldNotify(event) {
+		return nil
+	}
+	message := &Message{
+		Event: event,
+		Step: step,
+		To: splitAddresses(notification.To),
+		CC: splitAddresses(notification.CC),
+		Time: time.Now(),
+	}
+	if status , ok := stepConfig["status"].(bool); ok {
+		message.Status = status
+	}
+	if state , ok := stepConfig[models.STEP_STATE_KEY]; ok {
+		message.State = fmt.Sprintf("%v",state)
+	}
+	variables := map[string]interface{}{
+		"event": message.Event,
+		"step": message.Step,
+		"status": message.Status,
+		"state": message.State,
+	}
+	exprManager := &expr.ExprManager{}
+	message.Title = exprManager.Render(notification.Title,variables,stepConfig)
+	message.Body = exprManager.Render(notification.Body,variables,stepConfig)
+	notifiers , err := GetNotifiers(notification,systemConfig)
+	errs := make([]string,0)
+	if err != nil {
+		errs = append(errs,err.Error())
+	}
+	for _ , notifier := range notifiers {
+		if err := notifier.Notify(message); err != nil {
+			errs = append(errs,err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Unable to send the notification of (%s): %s",step,strings.Join(errs,", "))
+	}
+	return nil
+}
@@ -0,0 +1,405 @@
+package notifications
+
+import (
+	"bioflows/models"
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// receivedMail is what the SMTP stand-in has received during a single session
+type receivedMail struct {
+	From string
+	To   []string
+	Auth string
+	Data string
+	TLS  bool
+}
+
+/*
+	smtpServer is a minimal in-process SMTP server, it advertises STARTTLS when startTLS is set
+	and serves implicit TLS when its listener is a TLS listener.
+ */
+type smtpServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	startTLS  bool
+	mails     chan receivedMail
+}
+
+func newSMTPServer(t *testing.T , tlsConfig *tls.Config , startTLS bool , implicitTLS bool) *smtpServer {
+	listener , err := net.Listen("tcp","127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if implicitTLS {
+		listener = tls.NewListener(listener,tlsConfig)
+	}
+	server := &smtpServer{listener: listener,tlsConfig: tlsConfig,startTLS: startTLS,mails: make(chan receivedMail,1)}
+	go server.serve()
+	t.Cleanup(func(){
+		listener.Close()
+	})
+	return server
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) serve() {
+	for {
+		conn , err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(conn)
+	}
+}
+
+func (s *smtpServer) session(conn net.Conn) {
+	defer conn.Close()
+	_ , isTLS := conn.(*tls.Conn)
+	mail := receivedMail{TLS: isTLS}
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	reply := func(lines ...string) {
+		conn.Write([]byte(strings.Join(lines,"\r\n") + "\r\n"))
+	}
+	reply("220 localhost ESMTP")
+	for {
+		line , err := reader.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line," ",2)[0])
+		switch command {
+		case "EHLO":
+			lines := []string{"250-localhost"}
+			if s.startTLS && !mail.TLS {
+				lines = append(lines,"250-STARTTLS")
+			}
+			reply(append(lines,"250 AUTH PLAIN")...)
+		case "STARTTLS":
+			reply("220 Ready to start TLS")
+			tlsConn := tls.Server(conn,s.tlsConfig)
+			if err = tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn , mail.TLS = tlsConn , true
+			reader = textproto.NewReader(bufio.NewReader(conn))
+		case "AUTH":
+			fields := strings.Fields(line)
+			decoded , _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			mail.Auth = string(decoded)
+			reply("235 Authentication successful")
+		case "MAIL":
+			mail.From = strings.Trim(strings.TrimPrefix(line[len("MAIL "):],"FROM:"),"<>")
+			reply("250 OK")
+		case "RCPT":
+			mail.To = append(mail.To,strings.Trim(strings.TrimPrefix(line[len("RCPT "):],"TO:"),"<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data , err := reader.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.Data = string(data)
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			s.mails <- mail
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) received(t *testing.T) receivedMail {
+	select {
+	case mail := <- s.mails:
+		return mail
+	case <- time.After(5 * time.Second):
+		t.Fatal("the SMTP server has not received any message")
+	}
+	return receivedMail{}
+}
+
+// newCertificate creates a self-signed certificate for 127.0.0.1 along with a pool which trusts it
+func newCertificate(t *testing.T) (tls.Certificate,*x509.CertPool) {
+	key , err := ecdsa.GenerateKey(elliptic.P256(),rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		KeyUsage: x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA: true,
+	}
+	der , err := x509.CreateCertificate(rand.Reader,template,template,&key.PublicKey,key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed , err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der},PrivateKey: key} , pool
+}
+
+func testMessage() *Message {
+	return &Message{
+		Event: models.NOTIFY_ON_FAILURE,
+		Step: "align",
+		Title: "align has failed",
+		Body: "first line\nsecond line",
+		To: []string{"to@example.com"},
+		CC: []string{"cc@example.com"},
+		Time: time.Now(),
+	}
+}
+
+func checkMail(t *testing.T , mail receivedMail , expectTLS bool) {
+	if mail.TLS != expectTLS {
+		t.Errorf("expected TLS to be %v, got %v",expectTLS,mail.TLS)
+	}
+	if mail.From != "bioflows@example.com" {
+		t.Errorf("unexpected sender (%s)",mail.From)
+	}
+	if strings.Join(mail.To,",") != "to@example.com,cc@example.com" {
+		t.Errorf("unexpected recipients %v",mail.To)
+	}
+	if mail.Auth != "\x00bioflows\x00secret" {
+		t.Errorf("unexpected credentials (%q)",mail.Auth)
+	}
+	for _ , expected := range []string{"Subject: align has failed","Cc: cc@example.com","first line\nsecond line"} {
+		if !strings.Contains(mail.Data,expected) {
+			t.Errorf("the message does not contain (%s):\n%s",expected,mail.Data)
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	certificate , pool := newCertificate(t)
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{certificate}}
+	tests := []struct {
+		name        string
+		startTLS    bool
+		implicitTLS bool
+	}{
+		{name: "plain"},
+		{name: "starttls",startTLS: true},
+		{name: "implicit tls",implicitTLS: true},
+	}
+	for _ , test := range tests {
+		t.Run(test.name,func(t *testing.T){
+			server := newSMTPServer(t,serverConfig,test.startTLS,test.implicitTLS)
+			notifier := &SMTPNotifier{
+				Host: "127.0.0.1",
+				Port: server.port(),
+				Username: "bioflows",
+				Password: "secret",
+				From: "bioflows@example.com",
+				SSL: test.implicitTLS,
+				TLS: test.startTLS,
+				TLSConfig: &tls.Config{RootCAs: pool},
+			}
+			if err := notifier.Notify(testMessage()); err != nil {
+				t.Fatal(err)
+			}
+			checkMail(t,server.received(t),test.startTLS || test.implicitTLS)
+		})
+	}
+}
+
+func TestSMTPNotifierUntrustedCertificate(t *testing.T) {
+	certificate , _ := newCertificate(t)
+	server := newSMTPServer(t,&tls.Config{Certificates: []tls.Certificate{certificate}},true,false)
+	notifier := &SMTPNotifier{Host: "127.0.0.1",Port: server.port(),From: "bioflows@example.com",TLS: true}
+	if err := notifier.Notify(testMessage()); err == nil {
+		t.Fatal("expected STARTTLS to fail with a certificate which is not trusted")
+	}
+}
+
+func TestSMTPNotifierSubjectHeader(t *testing.T) {
+	server := newSMTPServer(t,nil,false,false)
+	notifier := &SMTPNotifier{Host: "127.0.0.1",Port: server.port(),From: "bioflows@example.com"}
+	message := testMessage()
+	message.Title = "align has failed\r\nBcc: attacker@example.com"
+	if err := notifier.Notify(message); err != nil {
+		t.Fatal(err)
+	}
+	mail := server.received(t)
+	if strings.Contains(mail.Data,"\nBcc:") {
+		t.Errorf("the title has injected a header:\n%s",mail.Data)
+	}
+	if !strings.Contains(mail.Data,"Subject: align has failed Bcc: attacker@example.com\n") {
+		t.Errorf("the subject has not been kept on a single line:\n%s",mail.Data)
+	}
+	if encoded := encodeHeader("échec"); encoded != "=?utf-8?q?=C3=A9chec?=" {
+		t.Errorf("unexpected encoding of a non ASCII subject (%s)",encoded)
+	}
+}
+
+func TestNewSMTPNotifier(t *testing.T) {
+	notifier , err := NewSMTPNotifier(map[string]interface{}{"host": "smtp.example.com","ssl": true,"username": "bioflows@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notifier.Port != 465 || notifier.From != "bioflows@example.com" {
+		t.Errorf("unexpected defaults, port: %d, from: %s",notifier.Port,notifier.From)
+	}
+	if _ , err = NewSMTPNotifier(map[string]interface{}{"port": 25}); err == nil {
+		t.Error("expected an error for an email section without a host")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan Message,1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter , r *http.Request){
+		var message Message
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- message
+	}))
+	defer server.Close()
+	if err := NewWebhookNotifier(server.URL).Notify(testMessage()); err != nil {
+		t.Fatal(err)
+	}
+	message := <- received
+	if message.Event != models.NOTIFY_ON_FAILURE || message.Step != "align" || message.Title != "align has failed" {
+		t.Errorf("unexpected payload %+v",message)
+	}
+	if len(message.To) != 0 {
+		t.Errorf("the recipients should not be posted, got %v",message.To)
+	}
+}
+
+func TestWebhookNotifierStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter , r *http.Request){
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	if err := NewWebhookNotifier(server.URL).Notify(testMessage()); err == nil {
+		t.Fatal("expected an error for a non 2xx response")
+	}
+}
+
+func readMessages(t *testing.T , path string) []Message {
+	contents , err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := make([]Message,0)
+	for _ , line := range strings.Split(strings.TrimSpace(string(contents)),"\n") {
+		var message Message
+		if err := json.Unmarshal([]byte(line),&message); err != nil {
+			t.Fatalf("invalid line (%s): %s",line,err.Error())
+		}
+		messages = append(messages,message)
+	}
+	return messages
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(),"nested","notifications.jsonl")
+	notifier := &FileNotifier{Path: path}
+	for _ , step := range []string{"align","sort"} {
+		message := testMessage()
+		message.Step = step
+		if err := notifier.Notify(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	messages := readMessages(t,path)
+	if len(messages) != 2 || messages[0].Step != "align" || messages[1].Step != "sort" {
+		t.Errorf("unexpected messages %+v",messages)
+	}
+}
+
+func TestShouldNotify(t *testing.T) {
+	tests := []struct {
+		on       []string
+		event    string
+		expected bool
+	}{
+		{event: models.NOTIFY_ON_SUCCESS,expected: true},
+		{event: models.NOTIFY_ON_FAILURE,expected: true},
+		{event: "start",expected: false},
+		{on: []string{"failure"},event: models.NOTIFY_ON_SUCCESS,expected: false},
+		{on: []string{"failure"},event: models.NOTIFY_ON_FAILURE,expected: true},
+		{on: []string{" Start "},event: "start",expected: true},
+	}
+	for _ , test := range tests {
+		notification := &models.Notification{On: test.on}
+		if actual := notification.ShouldNotify(test.event); actual != test.expected {
+			t.Errorf("on: %v, event: %s, expected %v got %v",test.on,test.event,test.expected,actual)
+		}
+	}
+}
+
+func TestSendFiltersEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(),"notifications.jsonl")
+	notification := &models.Notification{
+		Title: "{{step}} is {{state}}",
+		Body: "status: {{status}}",
+		On: []string{models.NOTIFY_ON_FAILURE},
+		File: path,
+	}
+	stepConfig := models.FlowConfig{"status": false,models.STEP_STATE_KEY: "failed"}
+	if err := Send(notification,models.NOTIFY_ON_SUCCESS,"align",stepConfig,models.FlowConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if _ , err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("a notification has been sent for an event which is not in on")
+	}
+	if err := Send(notification,models.NOTIFY_ON_FAILURE,"align",stepConfig,models.FlowConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	messages := readMessages(t,path)
+	if len(messages) != 1 {
+		t.Fatalf("expected a single notification, got %d",len(messages))
+	}
+	if messages[0].Title != "align is failed" || messages[0].Body != "status: false" || messages[0].State != "failed" {
+		t.Errorf("unexpected message %+v",messages[0])
+	}
+}
+
+func TestSendWithoutEmailSection(t *testing.T) {
+	notification := &models.Notification{To: "to@example.com"}
+	if err := Send(notification,models.NOTIFY_ON_SUCCESS,"align",models.FlowConfig{},models.FlowConfig{}); err == nil {
+		t.Fatal("expected an error when emails are sent without the email section of BioFlows configuration")
+	}
+}
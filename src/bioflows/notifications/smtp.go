@@ -0,0 +1,157 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+/*
+	SMTPNotifier sends notifications by email. SSL connects with implicit TLS (usually port 465)
+	while TLS upgrades a plain connection with STARTTLS (usually port 587).
+ */
+type SMTPNotifier struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	SSL      bool
+	TLS      bool
+	// TLSConfig replaces the default TLS settings of SSL and TLS connections, e.g. to trust a private certificate authority
+	TLSConfig *tls.Config
+}
+
+func toInt(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case string:
+		converted , _ := strconv.Atoi(v)
+		return converted
+	}
+	return 0
+}
+
+// NewSMTPNotifier reads the email section of BioFlows configuration
+func NewSMTPNotifier(section interface{}) (*SMTPNotifier,error) {
+	email , ok := section.(map[string]interface{})
+	if !ok {
+		return nil , fmt.Errorf("the email section of BioFlows configuration is invalid")
+	}
+	getString := func(key string) string {
+		if value , ok := email[key]; ok && value != nil {
+			return fmt.Sprintf("%v",value)
+		}
+		return ""
+	}
+	notifier := &SMTPNotifier{
+		Host: getString("host"),
+		Port: toInt(email["port"]),
+		Username: getString("username"),
+		Password: getString("password"),
+		From: getString("from"),
+	}
+	notifier.SSL , _ = email["ssl"].(bool)
+	notifier.TLS , _ = email["tls"].(bool)
+	if len(notifier.Host) == 0 {
+		return nil , fmt.Errorf("the email section of BioFlows configuration has no host")
+	}
+	if notifier.Port == 0 {
+		notifier.Port = 25
+		if notifier.SSL {
+			notifier.Port = 465
+		}
+	}
+	if len(notifier.From) == 0 {
+		notifier.From = notifier.Username
+	}
+	return notifier , nil
+}
+
+// encodeHeader keeps a rendered value on a single header line, so it cannot add headers of its own, and encodes non ASCII text
+func encodeHeader(value string) string {
+	value = strings.Join(strings.FieldsFunc(value,func(r rune) bool {
+		return r == '\r' || r == '\n'
+	})," ")
+	return mime.QEncoding.Encode("utf-8",value)
+}
+
+func (s *SMTPNotifier) dial() (*smtp.Client,error) {
+	address := net.JoinHostPort(s.Host,strconv.Itoa(s.Port))
+	tlsConfig := &tls.Config{ServerName: s.Host}
+	if s.TLSConfig != nil {
+		tlsConfig = s.TLSConfig.Clone()
+		if len(tlsConfig.ServerName) == 0 {
+			tlsConfig.ServerName = s.Host
+		}
+	}
+	if s.SSL {
+		conn , err := tls.Dial("tcp",address,tlsConfig)
+		if err != nil {
+			return nil , err
+		}
+		return smtp.NewClient(conn,s.Host)
+	}
+	client , err := smtp.Dial(address)
+	if err != nil {
+		return nil , err
+	}
+	if s.TLS {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil , err
+		}
+	}
+	return client , nil
+}
+
+func (s *SMTPNotifier) Notify(message *Message) error {
+	client , err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok , _ := client.Extension("AUTH"); ok && len(s.Username) > 0 {
+		err = client.Auth(smtp.PlainAuth("",s.Username,s.Password,s.Host))
+		if err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(s.From); err != nil {
+		return err
+	}
+	recipients := append(append([]string{},message.To...),message.CC...)
+	for _ , recipient := range recipients {
+		if err = client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	writer , err := client.Data()
+	if err != nil {
+		return err
+	}
+	content := &bytes.Buffer{}
+	fmt.Fprintf(content,"From: %s\r\n",s.From)
+	fmt.Fprintf(content,"To: %s\r\n",strings.Join(message.To,", "))
+	if len(message.CC) > 0 {
+		fmt.Fprintf(content,"Cc: %s\r\n",strings.Join(message.CC,", "))
+	}
+	fmt.Fprintf(content,"Subject: %s\r\n",encodeHeader(message.Title))
+	fmt.Fprintf(content,"MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	content.WriteString(strings.ReplaceAll(message.Body,"\n","\r\n"))
+	if _ , err = writer.Write(content.Bytes()); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
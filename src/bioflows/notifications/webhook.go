@@ -0,0 +1,35 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookNotifier posts notifications as JSON to the given URL
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url,Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (w *WebhookNotifier) Notify(message *Message) error {
+	payload , err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	resp , err := w.Client.Post(w.URL,"application/json",bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook (%s) has responded with %s",w.URL,resp.Status)
+	}
+	return nil
+}
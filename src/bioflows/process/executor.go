@@ -2,9 +2,13 @@ package process
 
 import (
 	"bytes"
+	"context"
+	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"syscall"
+	"time"
 )
 
 type CommandExecutor struct{
@@ -12,8 +16,16 @@ type CommandExecutor struct{
 	CommandDir string
 	InitialCommand string
 	PreCommandArgs []string
+	// Args is the exec form of the command, when it is set the process runs without any shell
+	Args           []string
+	// Stdout and Stderr receive the output of the command as it is produced, it is buffered when they are not set
+	Stdout         io.Writer
+	Stderr         io.Writer
+	// Env holds additional environment variables (NAME=value) on top of the environment of the current process
+	Env            []string
 	buffer         *bytes.Buffer
 	errorBuff      *bytes.Buffer
+	state          *os.ProcessState
 }
 
 func (e *CommandExecutor) Init() {
@@ -22,13 +34,47 @@ func (e *CommandExecutor) Init() {
 }
 
 func (e *CommandExecutor) Run() (int, error) {
+	return e.RunContext(context.Background())
+}
+
+// RunContext runs the command in its own process group, once the given context is done
+// the whole process group is killed so no orphaned children are left behind.
+func (e *CommandExecutor) RunContext(ctx context.Context) (int, error) {
 	e.buffer = &bytes.Buffer{}
 	e.errorBuff = &bytes.Buffer{}
 	cmd := exec.Command(e.InitialCommand, strings.Join(e.PreCommandArgs," "),e.Command)
+	if len(e.Args) > 0 {
+		cmd = exec.Command(e.Args[0],e.Args[1:]...)
+	}
 	cmd.Dir = e.CommandDir
+	if len(e.Env) > 0 {
+		cmd.Env = append(os.Environ(),e.Env...)
+	}
 	cmd.Stdout = e.buffer
 	cmd.Stderr = e.errorBuff
-	err := cmd.Run()
+	if e.Stdout != nil {
+		cmd.Stdout = e.Stdout
+	}
+	if e.Stderr != nil {
+		cmd.Stderr = e.Stderr
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	err := cmd.Start()
+	if err != nil {
+		return 1 , err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func(){
+		select {
+		case <- ctx.Done():
+			// Negative pid means the whole process group
+			syscall.Kill(-cmd.Process.Pid,syscall.SIGKILL)
+		case <- done:
+		}
+	}()
+	err = cmd.Wait()
+	e.state = cmd.ProcessState
 	exitCode := 0
 	if err != nil {
 		if exiterr , ok := err.(*exec.ExitError) ; ok {
@@ -37,10 +83,26 @@ func (e *CommandExecutor) Run() (int, error) {
 			}
 		}
 	}
-
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return exitCode , ctxErr
+	}
 	return exitCode , err
 }
 
+// GetUsage returns the cpu times and the peak resident set size (in bytes) of the command and the children it has waited for
+func (e CommandExecutor) GetUsage() (userTime time.Duration , systemTime time.Duration , peakRSS int64) {
+	if e.state == nil {
+		return
+	}
+	userTime = e.state.UserTime()
+	systemTime = e.state.SystemTime()
+	if rusage , ok := e.state.SysUsage().(*syscall.Rusage); ok {
+		// Maxrss is in kilobytes on Linux
+		peakRSS = int64(rusage.Maxrss) * 1024
+	}
+	return
+}
+
 func (e CommandExecutor) GetOutput() *bytes.Buffer {
 	return e.buffer
 }
@@ -48,5 +110,3 @@ func (e CommandExecutor) GetOutput() *bytes.Buffer {
 func (e CommandExecutor) GetError() *bytes.Buffer {
 	return e.errorBuff
 }
-
-
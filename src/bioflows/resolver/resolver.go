@@ -15,6 +15,16 @@ func ResolveLeaderKey() string {
 	return strings.Join([]string{config.BIOFLOWS_NAME, config.BIOFLOWS_NODES, config.BIOFLOWS_LEADER},"/")
 }
 
+func ResolveCacheKey(hash string) string {
+	//Cache Key: bioflows/cache/%hash
+	return strings.Join([]string{config.BIOFLOWS_NAME, config.BIOFLOWS_CACHE, hash},"/")
+}
+
+func ResolveRunKey(instanceId string) string {
+	//Run Key: bioflows/runs/%instanceId
+	return strings.Join([]string{config.BIOFLOWS_NAME, config.BIOFLOWS_RUNS, instanceId},"/")
+}
+
 func ResolvePipelineKey(pipelineId string) string {
 	return strings.Join([]string{config.BIOFLOWS_NAME, config.BIOFLOWS_PIPELINES,pipelineId},"/")
 }
@@ -6,6 +6,7 @@ import (
 	"bioflows/models"
 	"bioflows/scripts/io"
 	"errors"
+	"fmt"
 	"github.com/dop251/goja"
 	"io/ioutil"
 	"strings"
@@ -87,6 +88,33 @@ func (manager *JSScriptManager) RunScript(script models.Script,config models.Flo
 	return nil
 }
 
+// EvaluateConditions evaluates every condition as a javascript expression against the given configuration ("self"),
+// it returns false as soon as one of them is not satisfied.
+func (manager *JSScriptManager) EvaluateConditions(conditions []models.Scriptable,config models.FlowConfig) (bool,error) {
+	if len(conditions) == 0 {
+		return true , nil
+	}
+	vm := goja.New()
+	vm.Set("self",config)
+	vm.Set("io",&io.IO{
+		VM: vm,
+	})
+	for _ , condition := range conditions {
+		code := strings.TrimSpace(condition.ToString())
+		if len(code) == 0 {
+			continue
+		}
+		value , err := vm.RunString(code)
+		if err != nil {
+			return false , fmt.Errorf("Unable to evaluate condition (%s): %s",code,err.Error())
+		}
+		if !value.ToBoolean() {
+			return false , nil
+		}
+	}
+	return true , nil
+}
+
 func (manager *JSScriptManager) RunAfter(script models.Script,config models.FlowConfig) error {
 	return manager.RunScript(script,config)
 }
@@ -4,6 +4,7 @@ import (
 	"bioflows/executors"
 	"bioflows/models/pipelines"
 	"fmt"
+	"github.com/goombaio/dag"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"os"
@@ -29,16 +30,21 @@ func main(){
 		return
 	}
 	graph , err := pipelines.CreateGraph(pipeline)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	// Every slot holds the steps released once all the steps of the previous slots have completed
 	scheduler := executors.DagScheduler{}
-	rankedList , err := scheduler.Rank(pipeline,graph)
-	for index , sublist := range rankedList {
+	ready := scheduler.Prepare(pipeline,graph)
+	for index := 0 ; len(ready) > 0 ; index++ {
 		fmt.Printf("Slot: %d\n",index)
-		for _, node := range sublist {
-			if node == nil {
-				continue
-			}
+		next := make([]*dag.Vertex,0)
+		for _ , node := range ready {
 			fmt.Printf("Slot: %d , Node: %s\n",index,node.ID)
+			next = append(next,scheduler.Complete(node)...)
 		}
+		ready = next
 		fmt.Println("#################################################")
 	}
 